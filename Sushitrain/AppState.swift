@@ -14,6 +14,7 @@ struct StreamingProgress: Hashable, Equatable {
 	var path: String
 	var bytesSent: Int64
 	var bytesTotal: Int64
+	var stalled: Bool = false
 }
 
 enum FolderMetric: String {
@@ -1152,6 +1153,13 @@ extension SushitrainDelegate: SushitrainClientDelegateProtocol {
 			appState.changePublisher.send()
 		}
 	}
+
+	func onFolderCompletionChanged(_ folderID: String?, deviceID: String?, pct: Double) {
+		let appState = self.appState
+		DispatchQueue.main.async {
+			appState.changePublisher.send()
+		}
+	}
 }
 
 extension SushitrainDelegate: SushitrainStreamingServerDelegateProtocol {
@@ -1168,4 +1176,16 @@ extension SushitrainDelegate: SushitrainStreamingServerDelegateProtocol {
 			}
 		}
 	}
+
+	func onStreamStalled(_ folder: String?, path: String?, stalled: Bool) {
+		if let folder = folder, let path = path {
+			let appState = self.appState
+			DispatchQueue.main.async {
+				if var progress = appState.streamingProgress, progress.folder == folder, progress.path == path {
+					progress.stalled = stalled
+					appState.streamingProgress = progress
+				}
+			}
+		}
+	}
 }
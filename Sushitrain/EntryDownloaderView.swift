@@ -11,6 +11,7 @@ import UniformTypeIdentifiers
 private class DownloadOperation: NSObject, ObservableObject, SushitrainDownloadDelegateProtocol, @unchecked Sendable {
 	@Published var error: String? = nil
 	@Published var progressFraction: Double = 0.0
+	@Published var stalled: Bool = false
 	@Published var downloadedFileURL: URL? = nil
 	private var lock = NSLock()
 	private var cancelled = false
@@ -42,6 +43,12 @@ private class DownloadOperation: NSObject, ObservableObject, SushitrainDownloadD
 		}
 	}
 
+	func onStalled(_ stalled: Bool) {
+		DispatchQueue.main.async {
+			self.stalled = stalled
+		}
+	}
+
 	func isCancelled() -> Bool {
 		return self.lock.withLock {
 			return self.cancelled
@@ -114,7 +121,12 @@ struct EntryDownloaderView: View {
 					ContentUnavailableView {
 						ProgressView(value: downloadOperation.progressFraction, total: 1.0)
 					} description: {
-						Text("Downloading file...")
+						if downloadOperation.stalled {
+							Text("Waiting for a device that has this file to become available...")
+						}
+						else {
+							Text("Downloading file...")
+						}
 					}
 				}
 			}
@@ -64,6 +64,10 @@ extension SushitrainEntry: @retroactive Transferable {
 
 			func onProgress(_ fraction: Double) {
 			}
+
+			func onStalled(_ stalled: Bool) {
+				Log.info("DownloadFileToSent: stalled=\(stalled)")
+			}
 		}
 
 		let url = try await withCheckedThrowingContinuation { cont in
@@ -0,0 +1,172 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+)
+
+const bandwidthScheduleFileName = "bandwidth-schedule.json"
+
+// How often to check whether a different schedule entry applies
+const bandwidthScheduleCheckInterval = 30 * time.Second
+
+type BandwidthScheduleEntry struct {
+	StartMinute     int `json:"startMinute"` // Minute of the day (0-1439) at which this entry starts to apply
+	EndMinute       int `json:"endMinute"`   // Minute of the day at which this entry stops to apply (exclusive)
+	DownMbitsPerSec int `json:"downMbitsPerSec"`
+	UpMbitsPerSec   int `json:"upMbitsPerSec"`
+}
+
+const minutesPerDay = 24 * 60
+
+func (entry *BandwidthScheduleEntry) validate() error {
+	if entry.StartMinute < 0 || entry.StartMinute >= minutesPerDay || entry.EndMinute < 0 || entry.EndMinute > minutesPerDay {
+		return fmt.Errorf("invalid schedule entry time range: %d-%d", entry.StartMinute, entry.EndMinute)
+	}
+	if entry.DownMbitsPerSec < 0 || entry.UpMbitsPerSec < 0 {
+		return errors.New("bandwidth limits cannot be negative")
+	}
+	return nil
+}
+
+// Entries with an end before their start wrap around midnight
+func (entry *BandwidthScheduleEntry) appliesAt(minuteOfDay int) bool {
+	if entry.StartMinute <= entry.EndMinute {
+		return minuteOfDay >= entry.StartMinute && minuteOfDay < entry.EndMinute
+	}
+	return minuteOfDay >= entry.StartMinute || minuteOfDay < entry.EndMinute
+}
+
+// Returns the index of the first entry that applies at the specified time, or -1 if none applies
+func bandwidthScheduleEntryIndexAt(schedule []BandwidthScheduleEntry, t time.Time) int {
+	minuteOfDay := t.Hour()*60 + t.Minute()
+	for idx, entry := range schedule {
+		if entry.appliesAt(minuteOfDay) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (clt *Client) bandwidthSchedulePath() string {
+	return path.Join(clt.CurrentConfigDirectory(), bandwidthScheduleFileName)
+}
+
+// Set the bandwidth schedule from a JSON array of entries (see BandwidthScheduleEntry). When entries overlap, the first
+// one applies. Outside of any entry, bandwidth is not limited. The schedule is saved and restored at startup. Set an
+// empty array to disable scheduling (the currently active limits are left as-is).
+func (clt *Client) SetBandwidthScheduleJSON(js []byte) error {
+	var schedule []BandwidthScheduleEntry
+	if err := json.Unmarshal(js, &schedule); err != nil {
+		return err
+	}
+
+	for _, entry := range schedule {
+		if err := entry.validate(); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(clt.bandwidthSchedulePath(), data, 0o600); err != nil {
+		return err
+	}
+
+	clt.mutex.Lock()
+	clt.bandwidthSchedule = schedule
+	clt.appliedScheduleEntry = -2 // Force re-application
+	clt.mutex.Unlock()
+
+	return clt.applyBandwidthSchedule(time.Now())
+}
+
+// Returns the current bandwidth schedule as JSON array of entries
+func (clt *Client) CurrentBandwidthScheduleJSON() ([]byte, error) {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	if clt.bandwidthSchedule == nil {
+		return json.Marshal([]BandwidthScheduleEntry{})
+	}
+	return json.Marshal(clt.bandwidthSchedule)
+}
+
+func (clt *Client) loadBandwidthSchedule() {
+	data, err := os.ReadFile(clt.bandwidthSchedulePath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("could not read bandwidth schedule", "cause", err)
+		}
+		return
+	}
+
+	var schedule []BandwidthScheduleEntry
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		slog.Warn("could not parse bandwidth schedule", "cause", err)
+		return
+	}
+
+	clt.mutex.Lock()
+	clt.bandwidthSchedule = schedule
+	clt.appliedScheduleEntry = -2
+	clt.mutex.Unlock()
+}
+
+// Applies the limits of the schedule entry that applies at time t, but only when this differs from the entry that was
+// last applied (so that limits set manually during a period are not overwritten until the next boundary)
+func (clt *Client) applyBandwidthSchedule(t time.Time) error {
+	clt.mutex.Lock()
+	if len(clt.bandwidthSchedule) == 0 {
+		clt.mutex.Unlock()
+		return nil
+	}
+
+	idx := bandwidthScheduleEntryIndexAt(clt.bandwidthSchedule, t)
+	if idx == clt.appliedScheduleEntry {
+		clt.mutex.Unlock()
+		return nil
+	}
+
+	down, up := 0, 0
+	if idx >= 0 {
+		down = clt.bandwidthSchedule[idx].DownMbitsPerSec
+		up = clt.bandwidthSchedule[idx].UpMbitsPerSec
+	}
+	clt.appliedScheduleEntry = idx
+	clt.mutex.Unlock()
+
+	slog.Info("applying bandwidth schedule", "entry", idx, "down", down, "up", up)
+	return clt.SetBandwidthLimitsMbitsPerSec(down, up)
+}
+
+func (clt *Client) startBandwidthScheduler() {
+	clt.loadBandwidthSchedule()
+
+	ticker := time.NewTicker(bandwidthScheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := clt.applyBandwidthSchedule(time.Now()); err != nil {
+			slog.Warn("could not apply bandwidth schedule", "cause", err)
+		}
+
+		select {
+		case <-clt.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+)
+
+const cellularPolicyFileName = "cellular-policy.json"
+
+// Syncthing has no notion of cellular connections, so the per-folder policy is stored separately
+type cellularPolicy struct {
+	NoSyncOnCellular  []string `json:"noSyncOnCellular"`  // IDs of folders that should not sync on cellular
+	PausedForCellular []string `json:"pausedForCellular"` // IDs of folders that were paused by us because of cellular
+}
+
+func (clt *Client) cellularPolicyPath() string {
+	return path.Join(clt.CurrentConfigDirectory(), cellularPolicyFileName)
+}
+
+func (clt *Client) loadCellularPolicy() {
+	data, err := os.ReadFile(clt.cellularPolicyPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("could not read cellular policy", "cause", err)
+		}
+		return
+	}
+
+	var policy cellularPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		slog.Warn("could not parse cellular policy", "cause", err)
+		return
+	}
+
+	clt.mutex.Lock()
+	clt.cellularPolicy = policy
+	clt.mutex.Unlock()
+}
+
+// Must be called with clt.mutex held
+func (clt *Client) saveCellularPolicyLocked() error {
+	data, err := json.Marshal(clt.cellularPolicy)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(clt.cellularPolicyPath(), data, 0o600)
+}
+
+// Informs the client whether the device is currently connected through a cellular network. Folders that should not
+// sync on cellular are paused while on cellular, and resumed when this is called with false. Folders that were already
+// paused are left alone, and will not be resumed.
+func (clt *Client) SetOnCellular(onCellular bool) error {
+	clt.mutex.Lock()
+	clt.onCellular = onCellular
+	clt.mutex.Unlock()
+	return clt.applyCellularPolicy()
+}
+
+func (clt *Client) IsOnCellular() bool {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	return clt.onCellular
+}
+
+// Pauses or resumes folders according to their cellular policy and the current connection type
+func (clt *Client) applyCellularPolicy() error {
+	clt.mutex.Lock()
+	onCellular := clt.onCellular
+	noSync := slices.Clone(clt.cellularPolicy.NoSyncOnCellular)
+	pausedByUs := slices.Clone(clt.cellularPolicy.PausedForCellular)
+	clt.mutex.Unlock()
+
+	var errs []error
+	stillPaused := make([]string, 0)
+	for _, folderID := range pausedByUs {
+		fld := clt.FolderWithID(folderID)
+		if fld == nil {
+			continue
+		}
+		if onCellular && slices.Contains(noSync, folderID) {
+			stillPaused = append(stillPaused, folderID)
+			continue
+		}
+		if fld.IsPaused() {
+			slog.Info("resuming folder paused for cellular", "folderID", folderID)
+			if err := fld.SetPaused(false); err != nil {
+				errs = append(errs, err)
+				stillPaused = append(stillPaused, folderID)
+			}
+		}
+	}
+
+	if onCellular {
+		for _, folderID := range noSync {
+			fld := clt.FolderWithID(folderID)
+			if fld == nil || slices.Contains(stillPaused, folderID) || fld.IsPaused() {
+				continue
+			}
+			slog.Info("pausing folder on cellular", "folderID", folderID)
+			if err := fld.SetPaused(true); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			stillPaused = append(stillPaused, folderID)
+		}
+	}
+
+	clt.mutex.Lock()
+	clt.cellularPolicy.PausedForCellular = stillPaused
+	err := clt.saveCellularPolicyLocked()
+	clt.mutex.Unlock()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Set whether this folder should sync while on a cellular connection (see Client.SetOnCellular). Defaults to true.
+func (fld *Folder) SetSyncOnCellular(enabled bool) error {
+	clt := fld.client
+	clt.mutex.Lock()
+	noSync := clt.cellularPolicy.NoSyncOnCellular
+	if enabled {
+		noSync = slices.DeleteFunc(noSync, func(folderID string) bool { return folderID == fld.FolderID })
+	} else if !slices.Contains(noSync, fld.FolderID) {
+		noSync = append(noSync, fld.FolderID)
+	}
+	clt.cellularPolicy.NoSyncOnCellular = noSync
+	err := clt.saveCellularPolicyLocked()
+	clt.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return clt.applyCellularPolicy()
+}
+
+func (fld *Folder) SyncOnCellular() bool {
+	fld.client.mutex.Lock()
+	defer fld.client.mutex.Unlock()
+	return !slices.Contains(fld.client.cellularPolicy.NoSyncOnCellular, fld.FolderID)
+}
+
+// Returns true when this folder is currently paused because it should not sync on cellular
+func (fld *Folder) IsPausedForCellular() bool {
+	fld.client.mutex.Lock()
+	defer fld.client.mutex.Unlock()
+	return slices.Contains(fld.client.cellularPolicy.PausedForCellular, fld.FolderID)
+}
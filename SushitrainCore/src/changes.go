@@ -0,0 +1,79 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import "sync"
+
+// Number of changes (across all folders) kept for RecentChanges
+const maxRecentChanges = 500
+
+// Ring buffer holding the most recent changes
+type changeTail struct {
+	changes    []*Change
+	maxChanges int
+	next       int // Index at which the next change will be written
+	count      int
+	mutex      sync.Mutex
+}
+
+func newChangeTail(maxChanges int) *changeTail {
+	return &changeTail{
+		changes:    make([]*Change, maxChanges),
+		maxChanges: maxChanges,
+	}
+}
+
+func (ct *changeTail) append(change *Change) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	ct.changes[ct.next] = change
+	ct.next = (ct.next + 1) % ct.maxChanges
+	ct.count = min(ct.count+1, ct.maxChanges)
+}
+
+// Returns at most limit changes for the folder (or all folders when folderID is empty), most recent first. When limit
+// is zero or less, all matching changes are returned.
+func (ct *changeTail) recent(folderID string, limit int) []*Change {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	changes := make([]*Change, 0)
+	for i := range ct.count {
+		change := ct.changes[(ct.next-1-i+ct.maxChanges)%ct.maxChanges]
+		if folderID != "" && change.FolderID != folderID {
+			continue
+		}
+		changes = append(changes, change)
+		if limit > 0 && len(changes) >= limit {
+			break
+		}
+	}
+	return changes
+}
+
+type ChangeList struct {
+	changes []*Change
+}
+
+func (lst *ChangeList) Count() int {
+	return len(lst.changes)
+}
+
+func (lst *ChangeList) ItemAt(index int) *Change {
+	return lst.changes[index]
+}
+
+// Returns at most limit of the most recent local and remote changes in the folder (or in all folders when folderID
+// is empty), most recent first. Only changes since the client was started are available.
+func (clt *Client) RecentChanges(folderID string, limit int) []*Change {
+	return clt.recentChanges.recent(folderID, limit)
+}
+
+// Like RecentChanges, but returns the changes in a form that can be used from Swift
+func (clt *Client) RecentChangeList(folderID string, limit int) *ChangeList {
+	return &ChangeList{changes: clt.RecentChanges(folderID, limit)}
+}
@@ -0,0 +1,39 @@
+package sushitrain
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestChangeTail(t *testing.T) {
+	ct := newChangeTail(5)
+	if len(ct.recent("", 0)) != 0 {
+		t.Fatal("expected empty change tail")
+	}
+
+	for i := range 7 {
+		folderID := "a"
+		if i%2 == 1 {
+			folderID = "b"
+		}
+		ct.append(&Change{FolderID: folderID, Path: fmt.Sprintf("%d", i)})
+	}
+
+	paths := func(changes []*Change) []string {
+		return Map(changes, func(change *Change) string { return change.Path })
+	}
+
+	if p := paths(ct.recent("", 0)); !slices.Equal(p, []string{"6", "5", "4", "3", "2"}) {
+		t.Errorf("unexpected changes: %v", p)
+	}
+	if p := paths(ct.recent("", 2)); !slices.Equal(p, []string{"6", "5"}) {
+		t.Errorf("unexpected limited changes: %v", p)
+	}
+	if p := paths(ct.recent("b", 0)); !slices.Equal(p, []string{"5", "3"}) {
+		t.Errorf("unexpected changes for folder: %v", p)
+	}
+	if p := paths(ct.recent("c", 0)); len(p) != 0 {
+		t.Errorf("unexpected changes for unknown folder: %v", p)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const conflictPoliciesFileName = "conflict-policies.json"
+
+const (
+	ConflictPolicyManual       = "manual"
+	ConflictPolicyNewestWins   = "newest-wins"
+	ConflictPolicyLargestWins  = "largest-wins"
+	ConflictPolicyPreferDevice = "prefer-device:" // Followed by the device ID
+)
+
+// One of the variants of a file in conflict (the original or one of the conflict copies)
+type conflictVariant struct {
+	path       string
+	modTime    time.Time
+	size       int64
+	modifiedBy string // Short device ID
+}
+
+// Validates the policy and returns it in canonical form
+func parseConflictPolicy(policy string) (string, error) {
+	switch policy {
+	case "", ConflictPolicyManual:
+		return ConflictPolicyManual, nil
+	case ConflictPolicyNewestWins, ConflictPolicyLargestWins:
+		return policy, nil
+	}
+
+	if deviceIDString, ok := strings.CutPrefix(policy, ConflictPolicyPreferDevice); ok {
+		if deviceIDString == "" {
+			return "", errors.New("no device ID specified for conflict policy")
+		}
+		deviceID, err := protocol.DeviceIDFromString(deviceIDString)
+		if err != nil {
+			return "", err
+		}
+		return ConflictPolicyPreferDevice + deviceID.String(), nil
+	}
+	return "", fmt.Errorf("invalid conflict policy: %q", policy)
+}
+
+// Returns the index of the variant that should be kept according to the policy, or -1 when the policy does not decide.
+// The first variant is the original; it wins ties.
+func chooseConflictWinner(policy string, variants []conflictVariant) int {
+	if len(variants) < 2 {
+		return -1
+	}
+
+	switch policy {
+	case ConflictPolicyNewestWins:
+		winner := 0
+		for idx, variant := range variants {
+			if variant.modTime.After(variants[winner].modTime) {
+				winner = idx
+			}
+		}
+		return winner
+
+	case ConflictPolicyLargestWins:
+		winner := 0
+		for idx, variant := range variants {
+			if variant.size > variants[winner].size {
+				winner = idx
+			}
+		}
+		return winner
+	}
+
+	if deviceIDString, ok := strings.CutPrefix(policy, ConflictPolicyPreferDevice); ok {
+		deviceID, err := protocol.DeviceIDFromString(deviceIDString)
+		if err != nil {
+			return -1
+		}
+		for idx, variant := range variants {
+			if variant.modifiedBy == deviceID.Short().String() {
+				return idx
+			}
+		}
+	}
+	return -1
+}
+
+func (clt *Client) conflictPoliciesPath() string {
+	return path.Join(clt.CurrentConfigDirectory(), conflictPoliciesFileName)
+}
+
+func (clt *Client) loadConflictPolicies() {
+	data, err := os.ReadFile(clt.conflictPoliciesPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("could not read conflict policies", "cause", err)
+		}
+		return
+	}
+
+	var policies map[string]string
+	if err := json.Unmarshal(data, &policies); err != nil {
+		slog.Warn("could not parse conflict policies", "cause", err)
+		return
+	}
+
+	clt.mutex.Lock()
+	clt.conflictPolicies = policies
+	clt.mutex.Unlock()
+}
+
+// Must be called with clt.mutex held
+func (clt *Client) saveConflictPoliciesLocked() error {
+	data, err := json.Marshal(clt.conflictPolicies)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(clt.conflictPoliciesPath(), data, 0o600)
+}
+
+// Sets how new conflicts in this folder are resolved: "manual" (the default, conflicts are left alone), "newest-wins"
+// (the most recently modified variant is kept), "largest-wins" (the largest variant is kept) or "prefer-device:<id>"
+// (the variant last modified by the specified device is kept, if any). Conflicts are resolved automatically when the
+// conflict copy is detected (or when the folder is idle again, if it was scanning or pulling), by keeping the chosen
+// variant at the original path and deleting the others. Conflicts for which not all variants are present locally, or for
+// which a variant on disk was changed since it was last scanned, are left alone.
+func (fld *Folder) SetConflictPolicy(policy string) error {
+	policy, err := parseConflictPolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	clt := fld.client
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	if clt.conflictPolicies == nil {
+		clt.conflictPolicies = make(map[string]string)
+	}
+	if policy == ConflictPolicyManual {
+		delete(clt.conflictPolicies, fld.FolderID)
+	} else {
+		clt.conflictPolicies[fld.FolderID] = policy
+	}
+	return clt.saveConflictPoliciesLocked()
+}
+
+func (fld *Folder) ConflictPolicy() string {
+	fld.client.mutex.Lock()
+	defer fld.client.mutex.Unlock()
+
+	if policy, ok := fld.client.conflictPolicies[fld.FolderID]; ok {
+		return policy
+	}
+	return ConflictPolicyManual
+}
+
+// Called when a change to a file was detected, to resolve it according to the folder's conflict policy when the file
+// is a conflict copy. The conflict is queued and resolved once the folder is idle.
+func (clt *Client) handleConflictPolicyChange(folderID string, action string, filePath string) {
+	if action == "deleted" || !isConflictPath(filePath) {
+		return
+	}
+
+	clt.mutex.Lock()
+	policy, ok := clt.conflictPolicies[folderID]
+	if !ok || policy == ConflictPolicyManual {
+		clt.mutex.Unlock()
+		return
+	}
+	if clt.pendingConflicts[folderID] == nil {
+		clt.pendingConflicts[folderID] = make(map[string]bool)
+	}
+	clt.pendingConflicts[folderID][originalPathForConflictCopy(filePath)] = true
+	clt.mutex.Unlock()
+
+	go clt.resolvePendingConflicts(folderID)
+}
+
+// Resolves the queued conflicts for a folder, one at a time. Nothing is done while the folder is scanning or pulling;
+// the queued conflicts are then resolved when the folder becomes idle again.
+func (clt *Client) resolvePendingConflicts(folderID string) {
+	clt.conflictResolutionMutex.Lock()
+	defer clt.conflictResolutionMutex.Unlock()
+
+	fld := clt.FolderWithID(folderID)
+	if fld == nil {
+		return
+	}
+
+	state, err := fld.State()
+	if err != nil || state != model.FolderIdle.String() {
+		return
+	}
+
+	clt.mutex.Lock()
+	policy, ok := clt.conflictPolicies[folderID]
+	pending := clt.pendingConflicts[folderID]
+	delete(clt.pendingConflicts, folderID)
+	clt.mutex.Unlock()
+	if !ok || policy == ConflictPolicyManual {
+		return
+	}
+
+	for originalPath := range pending {
+		if err := fld.resolveConflict(policy, originalPath); err != nil {
+			slog.Warn("could not automatically resolve conflict", "folderID", folderID, "path", originalPath, "cause", err)
+		}
+	}
+}
+
+// Returns the variant for the file at the path, or nil when the file is not present locally or the file on disk differs
+// from what is in the index (i.e. it was changed since it was last scanned)
+func (fld *Folder) conflictVariant(ffs fs.Filesystem, modTimeWindow time.Duration, filePath string) (*conflictVariant, error) {
+	entry, err := fld.GetFileInformation(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || entry.IsDeleted() || entry.IsDirectory() || !entry.IsLocallyPresent() {
+		return nil, nil
+	}
+
+	stat, err := ffs.Lstat(osutil.NativeFilename(filePath))
+	if err != nil {
+		if fs.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	modTimeDifference := stat.ModTime().Sub(entry.info.ModTime()).Abs()
+	if !stat.IsRegular() || stat.Size() != entry.Size() || modTimeDifference > modTimeWindow {
+		return nil, nil
+	}
+
+	variant := &conflictVariant{
+		path:       filePath,
+		modTime:    entry.info.ModTime(),
+		size:       entry.Size(),
+		modifiedBy: entry.info.FileModifiedBy().String(),
+	}
+
+	// After being moved aside, a conflict copy is picked up by a local scan, so its modifier would be this device
+	if match := conflictingFileNamePattern.FindStringSubmatch(path.Base(filePath)); match != nil {
+		variant.modifiedBy = match[1]
+	}
+	return variant, nil
+}
+
+// Resolves the conflict for the file at originalPath according to the policy, keeping the winning variant at the
+// original path and deleting the other variants
+func (fld *Folder) resolveConflict(policy string, originalPath string) error {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return ErrStillLoading
+	}
+
+	dir := path.Dir(originalPath)
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+	conflicts, err := fld.ConflictsInSubdirectory(prefix)
+	if err != nil {
+		return err
+	}
+
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return errors.New("folder does not exist")
+	}
+	ffs := fc.Filesystem()
+
+	siblings := conflicts.ConflictSiblings(originalPath).data
+	variants := make([]conflictVariant, 0, len(siblings))
+	for _, siblingPath := range siblings {
+		variant, err := fld.conflictVariant(ffs, fc.ModTimeWindow(), siblingPath)
+		if err != nil {
+			return err
+		}
+		if variant == nil {
+			// Only resolve conflicts when we can see all variants
+			return nil
+		}
+		variants = append(variants, *variant)
+	}
+
+	winner := chooseConflictWinner(policy, variants)
+	if winner < 0 {
+		return nil
+	}
+
+	slog.Info("automatically resolving conflict", "folderID", fld.FolderID, "policy", policy, "original", originalPath, "kept", variants[winner].path)
+	if winner != 0 {
+		if err := ffs.Rename(osutil.NativeFilename(variants[winner].path), osutil.NativeFilename(originalPath)); err != nil {
+			return err
+		}
+	}
+	for idx, variant := range variants {
+		if idx == 0 || idx == winner {
+			continue
+		}
+		if err := fld.deleteLocalFileAndRedundantChildren(osutil.NativeFilename(variant.path)); err != nil {
+			return err
+		}
+	}
+	return fld.RescanSubdirectory(dir)
+}
@@ -0,0 +1,82 @@
+package sushitrain
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const testDeviceID = "P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2"
+
+func TestParseConflictPolicy(t *testing.T) {
+	valid := map[string]string{
+		"":                              ConflictPolicyManual,
+		"manual":                        ConflictPolicyManual,
+		"newest-wins":                   ConflictPolicyNewestWins,
+		"largest-wins":                  ConflictPolicyLargestWins,
+		"prefer-device:" + testDeviceID: ConflictPolicyPreferDevice + testDeviceID,
+	}
+	for policy, expected := range valid {
+		parsed, err := parseConflictPolicy(policy)
+		if err != nil || parsed != expected {
+			t.Errorf("%q: got %q, %v", policy, parsed, err)
+		}
+	}
+
+	for _, policy := range []string{"oldest-wins", "prefer-device:", "prefer-device:nonsense", "Manual"} {
+		if _, err := parseConflictPolicy(policy); err == nil {
+			t.Errorf("%q should be rejected", policy)
+		}
+	}
+}
+
+func TestChooseConflictWinner(t *testing.T) {
+	deviceID, err := protocol.DeviceIDFromString(testDeviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	variants := []conflictVariant{
+		{path: "a.txt", modTime: now, size: 10, modifiedBy: "AAAAAAA"},
+		{path: "a.sync-conflict-20240101-120000-AAAAAAA.txt", modTime: now.Add(time.Hour), size: 5, modifiedBy: deviceID.Short().String()},
+		{path: "a.sync-conflict-20240101-130000-BBBBBBB.txt", modTime: now.Add(-time.Hour), size: 10, modifiedBy: "BBBBBBB"},
+	}
+
+	if winner := chooseConflictWinner(ConflictPolicyNewestWins, variants); winner != 1 {
+		t.Errorf("newest-wins chose %d", winner)
+	}
+	if winner := chooseConflictWinner(ConflictPolicyLargestWins, variants); winner != 0 {
+		t.Errorf("largest-wins should prefer the original on ties, chose %d", winner)
+	}
+	if winner := chooseConflictWinner(ConflictPolicyPreferDevice+testDeviceID, variants); winner != 1 {
+		t.Errorf("prefer-device chose %d", winner)
+	}
+	if winner := chooseConflictWinner(ConflictPolicyPreferDevice+testDeviceID, variants[2:]); winner != -1 {
+		t.Errorf("a single variant should not be resolved, chose %d", winner)
+	}
+	if winner := chooseConflictWinner(ConflictPolicyManual, variants); winner != -1 {
+		t.Errorf("manual should not choose, chose %d", winner)
+	}
+}
+
+func TestConflictCopyModifierPattern(t *testing.T) {
+	// Short device IDs are base32 and will often contain digits
+	conflictCopy := "dir/photo.sync-conflict-20240101-120000-AB2CD7E.jpg"
+	match := conflictingFileNamePattern.FindStringSubmatch(conflictCopy)
+	if match == nil || match[1] != "AB2CD7E" {
+		t.Errorf("unexpected match: %v", match)
+	}
+
+	if original := originalPathForConflictCopy(conflictCopy); original != "dir/photo.jpg" {
+		t.Errorf("unexpected original path: %s", original)
+	}
+
+	conflicts := &Conflicts{conflictsByOriginal: map[string][]string{"dir/photo.jpg": {conflictCopy}}}
+	siblings := conflicts.ConflictSiblings(conflictCopy).data
+	if !slices.Equal(siblings, []string{"dir/photo.jpg", conflictCopy}) {
+		t.Errorf("unexpected siblings: %v", siblings)
+	}
+}
@@ -6,12 +6,18 @@
 package sushitrain
 
 import (
+	"bytes"
+	"log/slog"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/syncthing/syncthing/lib/osutil"
 )
 
 type Conflicts struct {
+	folder              *Folder
 	conflictCopies      []string
 	conflictsByOriginal map[string][]string
 }
@@ -20,7 +26,9 @@ func isConflictPath(path string) bool {
 	return strings.Contains(filepath.Base(path), ".sync-conflict-")
 }
 
-var conflictingFileNamePattern = regexp.MustCompile("\\.sync-conflict-[0-9]{8}-[0-9]{6}-[A-Z]{7}")
+// The last part is the short ID (base32, so it may contain the digits 2-7) of the device that modified the version that
+// was moved aside
+var conflictingFileNamePattern = regexp.MustCompile("\\.sync-conflict-[0-9]{8}-[0-9]{6}-([A-Z2-7]{7})")
 
 func originalPathForConflictCopy(path string) string {
 	return conflictingFileNamePattern.ReplaceAllLiteralString(path, "")
@@ -50,6 +58,7 @@ func (fld *Folder) ConflictsInSubdirectory(path string) (*Conflicts, error) {
 	}
 
 	return &Conflicts{
+		folder:              fld,
 		conflictCopies:      conflictCopies,
 		conflictsByOriginal: conflictsByOriginal,
 	}, nil
@@ -75,6 +84,58 @@ func (cf *Conflicts) ConflictSiblings(path string) *ListOfStrings {
 	return List(paths)
 }
 
+// Deletes (locally present) conflict copies that have exactly the same contents as their original, as determined by
+// comparing the block hashes. Returns the number of conflict copies deleted.
+func (cf *Conflicts) AutoMergeIdentical() (int, error) {
+	fld := cf.folder
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return 0, ErrStillLoading
+	}
+
+	removed := 0
+	changedDirs := make(map[string]bool)
+	for originalPath, copies := range cf.conflictsByOriginal {
+		original, err := fld.GetFileInformation(originalPath)
+		if err != nil {
+			return removed, err
+		}
+		if original == nil || original.IsDeleted() || original.IsDirectory() || len(original.info.BlocksHash) == 0 {
+			continue
+		}
+
+		remaining := make([]string, 0)
+		for _, copyPath := range copies {
+			conflictCopy, err := fld.GetFileInformation(copyPath)
+			if err != nil {
+				return removed, err
+			}
+
+			isIdentical := conflictCopy != nil && !conflictCopy.IsDeleted() && conflictCopy.IsLocallyPresent() &&
+				conflictCopy.Size() == original.Size() && bytes.Equal(conflictCopy.info.BlocksHash, original.info.BlocksHash)
+			if !isIdentical {
+				remaining = append(remaining, copyPath)
+				continue
+			}
+
+			slog.Info("removing conflict copy identical to original", "original", originalPath, "copy", copyPath)
+			if err := fld.deleteLocalFileAndRedundantChildren(osutil.NativeFilename(copyPath)); err != nil {
+				return removed, err
+			}
+			removed += 1
+			changedDirs[filepath.Dir(copyPath)] = true
+			cf.conflictCopies = slices.DeleteFunc(cf.conflictCopies, func(p string) bool { return p == copyPath })
+		}
+		cf.conflictsByOriginal[originalPath] = remaining
+	}
+
+	for dir := range changedDirs {
+		if err := fld.RescanSubdirectory(dir); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
 // Returns whether this file was created as a result of a conflict
 func (entry *Entry) IsConflictCopy() bool {
 	// Not perfect, but this is how Syncthing does it
@@ -0,0 +1,208 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Maximum time spent resolving or dialing a single address during diagnostics
+const connectivityCheckTimeout = 3 * time.Second
+
+const (
+	ConnectivityStageListening = "listening"
+	ConnectivityStageDiscovery = "discovery"
+	ConnectivityStageResolve   = "resolve"
+	ConnectivityStageDial      = "dial"
+	ConnectivityStageRelay     = "relay"
+)
+
+type ConnectivityStage struct {
+	Stage  string
+	Passed bool
+	Detail string // What was found, e.g. the addresses that were tried
+	Hint   string // Suggestion for the user when the stage did not pass
+}
+
+type ConnectivityReport struct {
+	DeviceID    string
+	IsConnected bool
+	stages      []*ConnectivityStage
+}
+
+func (report *ConnectivityReport) StageCount() int {
+	return len(report.stages)
+}
+
+func (report *ConnectivityReport) StageAt(index int) *ConnectivityStage {
+	return report.stages[index]
+}
+
+// Returns the first stage that did not pass, or nil when all stages passed
+func (report *ConnectivityReport) FirstFailedStage() *ConnectivityStage {
+	for _, stage := range report.stages {
+		if !stage.Passed {
+			return stage
+		}
+	}
+	return nil
+}
+
+func (report *ConnectivityReport) JSON() ([]byte, error) {
+	return json.Marshal(struct {
+		DeviceID    string
+		IsConnected bool
+		Stages      []*ConnectivityStage
+	}{report.DeviceID, report.IsConnected, report.stages})
+}
+
+func (report *ConnectivityReport) add(stage string, passed bool, detail string, hint string) {
+	if passed {
+		hint = ""
+	}
+	report.stages = append(report.stages, &ConnectivityStage{
+		Stage:  stage,
+		Passed: passed,
+		Detail: detail,
+		Hint:   hint,
+	})
+}
+
+// Checks, stage by stage, whether a connection to the specified device could be established. This blocks while
+// addresses are resolved and dialed, so it should not be called from the main thread.
+func (clt *Client) DiagnoseConnectivity(deviceID string) (*ConnectivityReport, error) {
+	if clt.app == nil || clt.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	devID, err := protocol.DeviceIDFromString(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceConfig, ok := clt.config.Device(devID)
+	if !ok {
+		return nil, fmt.Errorf("device not found: %s", deviceID)
+	}
+
+	report := &ConnectivityReport{
+		DeviceID:    devID.String(),
+		IsConnected: clt.app.Internals.IsConnectedTo(devID),
+	}
+	options := clt.config.Options()
+
+	// Are we listening for incoming connections?
+	clt.mutex.Lock()
+	listenAddresses := make([]string, 0)
+	for _, addrs := range clt.ResolvedListenAddresses {
+		listenAddresses = append(listenAddresses, addrs...)
+	}
+	discovered := slices.Clone(clt.discoveredAddresses[devID.String()])
+	clt.mutex.Unlock()
+
+	report.add(ConnectivityStageListening, clt.IsListening() && len(listenAddresses) > 0, strings.Join(listenAddresses, ", "),
+		"This device is not accepting incoming connections. Enable listening in the settings, or make sure the other device is able to accept connections.")
+
+	// Do we know where to find the peer?
+	candidates := make([]string, 0)
+	isDynamic := false
+	for _, address := range deviceConfig.Addresses {
+		if address == "dynamic" {
+			isDynamic = true
+		} else {
+			candidates = append(candidates, address)
+		}
+	}
+	if isDynamic {
+		for _, address := range discovered {
+			if !slices.Contains(candidates, address) {
+				candidates = append(candidates, address)
+			}
+		}
+	}
+
+	discoveryHint := "No addresses are known for this device. Enter its address manually, or make sure the device is online."
+	if isDynamic && !options.GlobalAnnEnabled && !options.LocalAnnEnabled {
+		discoveryHint = "Both global and local discovery are disabled. Enable discovery, or enter the address of this device manually."
+	}
+	report.add(ConnectivityStageDiscovery, len(candidates) > 0, strings.Join(candidates, ", "), discoveryHint)
+
+	// Can the addresses be resolved? Relay addresses are left out, these are checked separately
+	ctx, cancel := context.WithTimeout(clt.ctx, connectivityCheckTimeout)
+	defer cancel()
+
+	dialable := make([]string, 0)
+	resolveFailures := make([]string, 0)
+	for _, candidate := range candidates {
+		addrURL, err := url.Parse(candidate)
+		if err != nil || strings.HasPrefix(addrURL.Scheme, "relay") {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(addrURL.Host)
+		if err != nil {
+			resolveFailures = append(resolveFailures, candidate)
+			continue
+		}
+
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil || len(ips) == 0 {
+			resolveFailures = append(resolveFailures, candidate)
+			continue
+		}
+
+		// Only TCP addresses can be dialed without setting up a full QUIC session
+		if strings.HasPrefix(addrURL.Scheme, "tcp") {
+			for _, ip := range ips {
+				dialable = append(dialable, net.JoinHostPort(ip, port))
+			}
+		}
+	}
+
+	resolveDetail := ""
+	if len(resolveFailures) > 0 {
+		resolveDetail = "unresolvable: " + strings.Join(resolveFailures, ", ")
+	}
+	report.add(ConnectivityStageResolve, len(candidates) == 0 || len(resolveFailures) < len(candidates), resolveDetail,
+		"None of the addresses of this device could be resolved. Check the addresses entered for this device and your DNS settings.")
+
+	// Can we reach any of the addresses directly?
+	reached := ""
+	dialer := net.Dialer{Timeout: connectivityCheckTimeout}
+	for _, address := range dialable {
+		dialCtx, dialCancel := context.WithTimeout(clt.ctx, connectivityCheckTimeout)
+		conn, err := dialer.DialContext(dialCtx, "tcp", address)
+		dialCancel()
+		if err == nil {
+			conn.Close()
+			reached = address
+			break
+		}
+	}
+	report.add(ConnectivityStageDial, reached != "" || report.IsConnected, reached,
+		"The device could not be reached directly. A firewall or router may be blocking the connection; enable NAT traversal or a relay.")
+
+	// Do we have a relay to fall back on?
+	relayAddresses := Filter(listenAddresses, func(address string) bool {
+		return strings.HasPrefix(address, "relay://")
+	})
+	relayHint := "No relay is available."
+	if !options.RelaysEnabled {
+		relayHint = "Relaying is disabled. Enable relays to connect to devices that cannot be reached directly."
+	}
+	report.add(ConnectivityStageRelay, len(relayAddresses) > 0, strings.Join(relayAddresses, ", "), relayHint)
+
+	return report, nil
+}
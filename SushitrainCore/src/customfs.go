@@ -10,18 +10,23 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/gobwas/glob"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 )
 
 type customFilesystem struct {
-	fsType fs.FilesystemType
-	uri    string
-	root   CustomFileEntry
+	fsType            fs.FilesystemType
+	uri               string
+	root              CustomFileEntry
+	allowedExtensions []string                  // Lowercase, including the dot. When empty, all files are exposed
+	watcher           CustomFilesystemWatchable // Nil when the filesystem type does not support watching
 }
 
 type customFile struct {
@@ -51,6 +56,62 @@ type CustomFilesystemType interface {
 	Root(uri string) (CustomFileEntry, error)
 }
 
+// Swift-side interface to report changes on a custom filesystem, so that folders on the filesystem are rescanned when
+// something changes instead of only at the scan interval. See RegisterCustomFilesystemTypeWithWatcher.
+type CustomFilesystemWatchable interface {
+	// Starts reporting changes below `path` (relative to the root) of the filesystem at `uri` to the sink
+	StartWatching(uri string, path string, sink *WatchSink) error
+
+	// Called when changes should no longer be reported to the sink
+	StopWatching(sink *WatchSink)
+}
+
+// Receives changes from a CustomFilesystemWatchable. Paths are relative to the root of the filesystem.
+type WatchSink struct {
+	events  chan fs.Event
+	errors  chan error
+	ignore  fs.Matcher
+	context context.Context
+}
+
+// Reports that the file or directory at path was created or modified
+func (sink *WatchSink) Changed(path string) {
+	sink.send(path, fs.NonRemove)
+}
+
+// Reports that the file or directory at path was removed
+func (sink *WatchSink) Removed(path string) {
+	sink.send(path, fs.Remove)
+}
+
+// Reports that watching failed. Watching will be restarted later.
+func (sink *WatchSink) Failed(message string) {
+	select {
+	case sink.errors <- errors.New(message):
+	case <-sink.context.Done():
+	}
+}
+
+// Returns true when changes are no longer of interest
+func (sink *WatchSink) IsStopped() bool {
+	return sink.context.Err() != nil
+}
+
+func (sink *WatchSink) send(path string, eventType fs.EventType) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		path = "."
+	}
+	if sink.ignore != nil && sink.ignore.Match(path).IsIgnored() {
+		return
+	}
+
+	select {
+	case sink.events <- fs.Event{Name: path, Type: eventType}:
+	case <-sink.context.Done():
+	}
+}
+
 // The custom**-types should conform to the corresponding Syncthing filesystem interfaces
 var _ fs.Filesystem = &customFilesystem{}
 var _ fs.File = &customFile{}
@@ -58,7 +119,50 @@ var _ fs.FileInfo = &customFileWrapper{}
 
 var errNotImplemented = errors.New("not implemented by custom filesystem")
 
+// Names of custom filesystem types registered through RegisterCustomFilesystemType
+var customFilesystemTypes = make([]string, 0)
+var customFilesystemTypesMutex sync.Mutex
+
 func RegisterCustomFilesystemType(fsType string, fsHandler CustomFilesystemType) {
+	RegisterCustomFilesystemTypeWithExtensions(fsType, fsHandler, nil)
+}
+
+/*
+* Like RegisterCustomFilesystemType, but only exposes files with one of the given extensions (e.g. "jpg" or ".heic",
+case-insensitive) in directory listings. Directories are always exposed. When `allowedExtensions` is nil or empty, all
+files are exposed.
+*/
+func RegisterCustomFilesystemTypeWithExtensions(fsType string, fsHandler CustomFilesystemType, allowedExtensions *ListOfStrings) {
+	registerCustomFilesystemType(fsType, fsHandler, nil, allowedExtensions)
+}
+
+// Like RegisterCustomFilesystemTypeWithExtensions, but changes on the filesystem are reported by the watcher. The watcher
+// is passed separately because objects implemented in Swift only conform to the interface they are passed as.
+func RegisterCustomFilesystemTypeWithWatcher(fsType string, fsHandler CustomFilesystemType, watcher CustomFilesystemWatchable, allowedExtensions *ListOfStrings) {
+	registerCustomFilesystemType(fsType, fsHandler, watcher, allowedExtensions)
+}
+
+func registerCustomFilesystemType(fsType string, fsHandler CustomFilesystemType, watcher CustomFilesystemWatchable, allowedExtensions *ListOfStrings) {
+	extensions := make([]string, 0)
+	if allowedExtensions != nil {
+		for _, ext := range allowedExtensions.data {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext == "" {
+				continue
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			extensions = append(extensions, ext)
+		}
+	}
+
+	customFilesystemTypesMutex.Lock()
+	if !slices.Contains(customFilesystemTypes, fsType) {
+		customFilesystemTypes = append(customFilesystemTypes, fsType)
+	}
+	customFilesystemTypesMutex.Unlock()
+
 	fsTypeStruct := fs.FilesystemType(fsType)
 	fs.RegisterFilesystemType(fsTypeStruct, func(uri string, _opts ...fs.Option) (fs.Filesystem, error) {
 		root, err := fsHandler.Root(uri)
@@ -67,13 +171,23 @@ func RegisterCustomFilesystemType(fsType string, fsHandler CustomFilesystemType)
 		}
 
 		return &customFilesystem{
-			fsType: fsTypeStruct,
-			uri:    uri,
-			root:   root,
+			fsType:            fsTypeStruct,
+			uri:               uri,
+			root:              root,
+			allowedExtensions: extensions,
+			watcher:           watcher,
 		}, nil
 	})
 }
 
+// Returns whether the entry should appear in the filesystem, based on the allowed extensions
+func (p *customFilesystem) isExposed(entry CustomFileEntry) bool {
+	if len(p.allowedExtensions) == 0 || entry.IsDir() {
+		return true
+	}
+	return slices.Contains(p.allowedExtensions, strings.ToLower(filepath.Ext(entry.Name())))
+}
+
 func (p *customFilesystem) Roots() ([]string, error) {
 	return []string{"/"}, nil
 }
@@ -97,16 +211,65 @@ func (p *customFilesystem) OpenFile(name string, flags int, mode fs.FileMode) (f
 	return &customFile{info: item, data: data, mut: &sync.Mutex{}}, nil
 }
 
+// Returns the paths of all entries that match the pattern. Supports '*' and '?' (which do not match the path separator)
+// and '**' (which does).
 func (p *customFilesystem) Glob(pattern string) ([]string, error) {
-	panic("unimplemented")
+	gl, err := glob.Compile(strings.TrimPrefix(pattern, "/"), '/')
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]string, 0)
+	err = p.walkEntries(p.root, "", func(path string) {
+		if gl.Match(path) {
+			matches = append(matches, path)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// Calls block with the full path of each entry below (but not including) item, depth first
+func (p *customFilesystem) walkEntries(item CustomFileEntry, prefix string, block func(path string)) error {
+	if !item.IsDir() {
+		return nil
+	}
+
+	childCount, err := item.ChildCount()
+	if err != nil {
+		return err
+	}
+
+	for i := range childCount {
+		child, err := item.ChildAt(i)
+		if err != nil {
+			return err
+		}
+		if !p.isExposed(child) {
+			continue
+		}
+
+		childPath := child.Name()
+		if prefix != "" {
+			childPath = prefix + "/" + childPath
+		}
+		block(childPath)
+
+		if err := p.walkEntries(child, childPath, block); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (p *customFilesystem) itemAt(path string) (*customFileWrapper, error) {
 	parts := strings.Split(path, "/")
 
 	item := p.root
-	for _, p := range parts {
-		if p == "." || p == "" {
+	for _, part := range parts {
+		if part == "." || part == "" {
 			continue
 		}
 
@@ -126,7 +289,7 @@ func (p *customFilesystem) itemAt(path string) (*customFileWrapper, error) {
 				return nil, err
 			}
 
-			if child.Name() == p {
+			if child.Name() == part && p.isExposed(child) {
 				item = child
 				found = true
 				break
@@ -158,6 +321,9 @@ func (p *customFilesystem) DirNames(name string) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
+		if !p.isExposed(child) {
+			continue
+		}
 		names = append(names, child.Name())
 	}
 
@@ -195,9 +361,62 @@ func (p *customFilesystem) Usage(name string) (fs.Usage, error) {
 	}, nil
 }
 
+// Calls walkFn for the entry at name and, when it is a directory, recursively for all entries below it. When walkFn
+// returns fs.SkipDir for a directory, its contents are skipped. When it does so for a file, the remaining entries in
+// the containing directory are skipped.
 func (p *customFilesystem) Walk(name string, walkFn fs.WalkFunc) error {
-	// Implemented by Syncthing itself through WalkFS
-	panic("unimplemented")
+	name = strings.Trim(name, "/")
+	item, err := p.itemAt(name)
+	if err != nil {
+		return walkFn(name, nil, err)
+	}
+
+	err = p.walk(item, walkFn)
+	if errors.Is(err, fs.SkipDir) {
+		return nil
+	}
+	return err
+}
+
+func (p *customFilesystem) walk(item *customFileWrapper, walkFn fs.WalkFunc) error {
+	if err := walkFn(item.fullName, item, nil); err != nil || !item.IsDir() {
+		return err
+	}
+
+	childCount, err := item.file.ChildCount()
+	if err != nil {
+		return walkFn(item.fullName, item, err)
+	}
+
+	for i := range childCount {
+		child, err := item.file.ChildAt(i)
+		if err != nil {
+			if err := walkFn(item.fullName, item, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if !p.isExposed(child) {
+			continue
+		}
+
+		childPath := child.Name()
+		if item.fullName != "" {
+			childPath = item.fullName + "/" + childPath
+		}
+
+		err = p.walk(&customFileWrapper{file: child, fullName: childPath}, walkFn)
+		if errors.Is(err, fs.SkipDir) {
+			if child.IsDir() {
+				continue
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // We support no options
@@ -288,7 +507,25 @@ func (p *customFilesystem) Unhide(name string) error {
 }
 
 func (p *customFilesystem) Watch(path string, ignore fs.Matcher, ctx context.Context, ignorePerms bool) (<-chan fs.Event, <-chan error, error) {
-	return nil, nil, errNotImplemented
+	if p.watcher == nil {
+		return nil, nil, errNotImplemented
+	}
+
+	sink := &WatchSink{
+		events:  make(chan fs.Event),
+		errors:  make(chan error),
+		ignore:  ignore,
+		context: ctx,
+	}
+	if err := p.watcher.StartWatching(p.uri, strings.Trim(path, "/"), sink); err != nil {
+		return nil, nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		p.watcher.StopWatching(sink)
+	}()
+	return sink.events, sink.errors, nil
 }
 
 // Photo file implementation
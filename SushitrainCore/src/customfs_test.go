@@ -0,0 +1,270 @@
+package sushitrain
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore/ignoreresult"
+)
+
+type testCustomFileEntry struct {
+	name     string
+	children []*testCustomFileEntry
+	data     []byte
+}
+
+func (e *testCustomFileEntry) Name() string {
+	return e.name
+}
+
+func (e *testCustomFileEntry) ChildCount() (int, error) {
+	return len(e.children), nil
+}
+
+func (e *testCustomFileEntry) ChildAt(index int) (CustomFileEntry, error) {
+	return e.children[index], nil
+}
+
+func (e *testCustomFileEntry) IsDir() bool {
+	return e.children != nil
+}
+
+func (e *testCustomFileEntry) Data() ([]byte, error) {
+	return e.data, nil
+}
+
+func (e *testCustomFileEntry) ModifiedTime() int64 {
+	return 0
+}
+
+func (e *testCustomFileEntry) Bytes() (int, error) {
+	return len(e.data), nil
+}
+
+func testDir(name string, children ...*testCustomFileEntry) *testCustomFileEntry {
+	return &testCustomFileEntry{name: name, children: children}
+}
+
+func testFile(name string) *testCustomFileEntry {
+	return &testCustomFileEntry{name: name, data: []byte(name)}
+}
+
+func newTestCustomFilesystem() *customFilesystem {
+	return &customFilesystem{
+		fsType: "test",
+		uri:    "test://",
+		root: testDir("",
+			testFile("a.jpg"),
+			testFile("b.png"),
+			testDir("2024",
+				testFile("c.jpg"),
+				testDir("05", testFile("d.jpg")),
+			),
+		),
+	}
+}
+
+func TestCustomFilesystemGlob(t *testing.T) {
+	cfs := newTestCustomFilesystem()
+
+	cases := map[string][]string{
+		"*.jpg":     {"a.jpg"},
+		"?.png":     {"b.png"},
+		"2024/*":    {"2024/c.jpg", "2024/05"},
+		"**.jpg":    {"a.jpg", "2024/c.jpg", "2024/05/d.jpg"},
+		"**/d.jpg":  {"2024/05/d.jpg"},
+		"/2024/05":  {"2024/05"},
+		"*.heic":    {},
+		"2024/**/*": {"2024/05/d.jpg"},
+	}
+
+	for pattern, expected := range cases {
+		matches, err := cfs.Glob(pattern)
+		if err != nil {
+			t.Fatalf("Glob(%q): %v", pattern, err)
+		}
+		if !slices.Equal(matches, expected) {
+			t.Errorf("Glob(%q) = %v, expected %v", pattern, matches, expected)
+		}
+	}
+
+	if _, err := cfs.Glob("[a"); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+}
+
+func TestCustomFilesystemWalk(t *testing.T) {
+	cfs := newTestCustomFilesystem()
+
+	walked := make([]string, 0)
+	err := cfs.Walk("", func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	expected := []string{"", "a.jpg", "b.png", "2024", "2024/c.jpg", "2024/05", "2024/05/d.jpg"}
+	if !slices.Equal(walked, expected) {
+		t.Errorf("Walk visited %v, expected %v", walked, expected)
+	}
+
+	// Skipping a directory skips its contents
+	walked = walked[:0]
+	err = cfs.Walk("2024", func(path string, info fs.FileInfo, err error) error {
+		walked = append(walked, path)
+		if info.IsDir() && path == "2024/05" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk(2024): %v", err)
+	}
+	expected = []string{"2024", "2024/c.jpg", "2024/05"}
+	if !slices.Equal(walked, expected) {
+		t.Errorf("Walk(2024) visited %v, expected %v", walked, expected)
+	}
+
+	if err := cfs.Walk("missing", func(path string, info fs.FileInfo, err error) error {
+		return err
+	}); err == nil {
+		t.Errorf("expected error when walking a missing path")
+	}
+}
+
+func TestCustomFilesystemAllowedExtensions(t *testing.T) {
+	cfs := newTestCustomFilesystem()
+	cfs.allowedExtensions = []string{".jpg"}
+
+	names, err := cfs.DirNames("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"a.jpg", "2024"}) {
+		t.Errorf("unexpected directory listing: %v", names)
+	}
+
+	if _, err := cfs.Stat("b.png"); err == nil {
+		t.Error("expected filtered file to not exist")
+	}
+	if _, err := cfs.Stat("2024/05/d.jpg"); err != nil {
+		t.Errorf("expected allowed file to exist: %v", err)
+	}
+
+	matches, err := cfs.Glob("**.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected no matches for filtered extension, got %v", matches)
+	}
+}
+
+type testWatchable struct {
+	sinks   chan *WatchSink
+	stopped chan *WatchSink
+}
+
+func (w *testWatchable) StartWatching(uri string, path string, sink *WatchSink) error {
+	w.sinks <- sink
+	return nil
+}
+
+func (w *testWatchable) StopWatching(sink *WatchSink) {
+	w.stopped <- sink
+}
+
+type testTmpMatcher struct{}
+
+func (testTmpMatcher) Match(name string) ignoreresult.R {
+	if strings.HasSuffix(name, ".tmp") {
+		return ignoreresult.Ignored
+	}
+	return ignoreresult.NotIgnored
+}
+
+func TestCustomFilesystemWatch(t *testing.T) {
+	cfs := newTestCustomFilesystem()
+	if _, _, err := cfs.Watch(".", testTmpMatcher{}, context.Background(), false); !errors.Is(err, errNotImplemented) {
+		t.Fatalf("expected watching to be unsupported without watcher, got %v", err)
+	}
+
+	watcher := &testWatchable{sinks: make(chan *WatchSink, 1), stopped: make(chan *WatchSink, 1)}
+	cfs.watcher = watcher
+	ctx, cancel := context.WithCancel(context.Background())
+	events, _, err := cfs.Watch(".", testTmpMatcher{}, ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := <-watcher.sinks
+
+	go func() {
+		sink.Changed("/2024/scratch.tmp") // Ignored
+		sink.Changed("/2024/c.jpg")
+		sink.Removed("a.jpg")
+	}()
+
+	expected := []fs.Event{{Name: "2024/c.jpg", Type: fs.NonRemove}, {Name: "a.jpg", Type: fs.Remove}}
+	for _, want := range expected {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("expected event %v, got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %v", want)
+		}
+	}
+
+	cancel()
+	select {
+	case stopped := <-watcher.stopped:
+		if stopped != sink || !sink.IsStopped() {
+			t.Error("expected sink to be stopped")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for watching to stop")
+	}
+
+	// Sending after stopping should not block
+	sink.Changed("b.png")
+}
+
+type testCustomFilesystemType struct{}
+
+func (testCustomFilesystemType) Root(uri string) (CustomFileEntry, error) {
+	return newTestCustomFilesystem().root, nil
+}
+
+func TestRegisterCustomFilesystemTypeWithWatcher(t *testing.T) {
+	watcher := &testWatchable{sinks: make(chan *WatchSink, 1), stopped: make(chan *WatchSink, 1)}
+	RegisterCustomFilesystemTypeWithWatcher("test-watched", testCustomFilesystemType{}, watcher, nil)
+
+	ffs := fs.NewFilesystem(fs.FilesystemType("test-watched"), "test://")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, _, err := ffs.Watch(".", testTmpMatcher{}, ctx, false); err != nil {
+		t.Fatalf("expected registered filesystem to be watchable: %v", err)
+	}
+	select {
+	case <-watcher.sinks:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for watching to start")
+	}
+
+	// Without a watcher, watching is not supported
+	RegisterCustomFilesystemTypeWithExtensions("test-unwatched", testCustomFilesystemType{}, nil)
+	ffs = fs.NewFilesystem(fs.FilesystemType("test-unwatched"), "test://")
+	if _, _, err := ffs.Watch(".", testTmpMatcher{}, ctx, false); err == nil {
+		t.Error("expected watching to be unsupported without watcher")
+	}
+}
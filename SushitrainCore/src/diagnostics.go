@@ -0,0 +1,181 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+)
+
+type folderDiagnostics struct {
+	FolderID          string `json:"folderID"`
+	Label             string `json:"label"`
+	FolderType        string `json:"folderType"`
+	State             string `json:"state"`
+	StateError        string `json:"stateError,omitempty"`
+	IsPaused          bool   `json:"isPaused"`
+	IsPausedCellular  bool   `json:"isPausedForCellular"`
+	IsSelective       bool   `json:"isSelective"`
+	IsEncryptedAtRest bool   `json:"isEncryptedAtRest"`
+}
+
+type deviceDiagnostics struct {
+	DeviceID          string    `json:"deviceID"`
+	Name              string    `json:"name"`
+	IsConnected       bool      `json:"isConnected"`
+	ConnectionType    string    `json:"connectionType,omitempty"`
+	ConnectionAddress string    `json:"connectionAddress,omitempty"`
+	IsConnectedRelay  bool      `json:"isConnectedViaRelay"`
+	IsPaused          bool      `json:"isPaused"`
+	LastSeen          time.Time `json:"lastSeen"`
+}
+
+type connectivityDiagnostics struct {
+	IsListening             bool                `json:"isListening"`
+	ListenAddresses         []string            `json:"listenAddresses"`
+	ResolvedListenAddresses map[string][]string `json:"resolvedListenAddresses"`
+	DiscoveryAddresses      []string            `json:"discoveryAddresses"`
+	DiscoveredAddresses     map[string][]string `json:"discoveredAddresses"`
+	IsLocalAnnounceEnabled  bool                `json:"isLocalAnnounceEnabled"`
+	IsGlobalAnnounceEnabled bool                `json:"isGlobalAnnounceEnabled"`
+	IsRelaysEnabled         bool                `json:"isRelaysEnabled"`
+	IsOnCellular            bool                `json:"isOnCellular"`
+	ConnectedPeerCount      int                 `json:"connectedPeerCount"`
+}
+
+func (clt *Client) folderDiagnostics() []folderDiagnostics {
+	folders := make([]folderDiagnostics, 0)
+	for _, folderID := range clt.Folders().data {
+		fld := clt.FolderWithID(folderID)
+		if fld == nil {
+			continue
+		}
+
+		diagnostics := folderDiagnostics{
+			FolderID:          fld.FolderID,
+			Label:             fld.Label(),
+			FolderType:        fld.FolderType(),
+			IsPaused:          fld.IsPaused(),
+			IsPausedCellular:  fld.IsPausedForCellular(),
+			IsSelective:       fld.IsSelective(),
+			IsEncryptedAtRest: fld.IsEncryptedAtRest(),
+		}
+		state, err := fld.State()
+		diagnostics.State = state
+		if err != nil {
+			diagnostics.StateError = err.Error()
+		}
+		folders = append(folders, diagnostics)
+	}
+	return folders
+}
+
+func (clt *Client) deviceDiagnostics() []deviceDiagnostics {
+	devices := make([]deviceDiagnostics, 0)
+	for _, deviceID := range clt.Peers().data {
+		peer := clt.PeerWithID(deviceID)
+		if peer == nil || peer.IsSelf() {
+			continue
+		}
+
+		diagnostics := deviceDiagnostics{
+			DeviceID:    peer.DeviceID(),
+			Name:        peer.Name(),
+			IsConnected: peer.IsConnected(),
+			IsPaused:    peer.IsPaused(),
+		}
+		if diagnostics.IsConnected {
+			diagnostics.ConnectionType = peer.ConnectionType()
+			diagnostics.ConnectionAddress = peer.ConnectionAddress()
+			diagnostics.IsConnectedRelay = peer.IsConnectedViaRelay()
+		}
+		if lastSeen := peer.LastSeen(); lastSeen != nil {
+			diagnostics.LastSeen = lastSeen.time
+		}
+		devices = append(devices, diagnostics)
+	}
+	return devices
+}
+
+func (clt *Client) connectivityDiagnostics() connectivityDiagnostics {
+	clt.mutex.Lock()
+	resolvedListenAddresses := maps.Clone(clt.ResolvedListenAddresses)
+	discoveredAddresses := maps.Clone(clt.discoveredAddresses)
+	clt.mutex.Unlock()
+
+	return connectivityDiagnostics{
+		IsListening:             clt.IsListening(),
+		ListenAddresses:         slices.Clone(clt.ListenAddresses().data),
+		ResolvedListenAddresses: resolvedListenAddresses,
+		DiscoveryAddresses:      slices.Clone(clt.DiscoveryAddresses().data),
+		DiscoveredAddresses:     discoveredAddresses,
+		IsLocalAnnounceEnabled:  clt.IsLocalAnnounceEnabled(),
+		IsGlobalAnnounceEnabled: clt.IsGlobalAnnounceEnabled(),
+		IsRelaysEnabled:         clt.IsRelaysEnabled(),
+		IsOnCellular:            clt.IsOnCellular(),
+		ConnectedPeerCount:      clt.ConnectedPeerCount(),
+	}
+}
+
+/*
+* Generates a zip archive that can be shared for support purposes, containing the running configuration, the log tail,
+a snapshot of the status of folders and devices, and connectivity information. Secrets (API key, GUI credentials and
+encryption passwords) are never included. When `redact` is set, device IDs, IP addresses and user paths are masked as well.
+*/
+func (clt *Client) ExportDiagnostics(redact bool) ([]byte, error) {
+	if clt.config == nil || clt.app == nil || clt.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	defer zipWriter.Close() // We might close twice but that's alright
+
+	sections := []struct {
+		name string
+		data any
+	}{
+		{"config.json", clt.getRedactedConfigFile()},
+		{"folders.json", clt.folderDiagnostics()},
+		{"devices.json", clt.deviceDiagnostics()},
+		{"connectivity.json", clt.connectivityDiagnostics()},
+	}
+
+	for _, section := range sections {
+		jsonData, err := json.MarshalIndent(section.data, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		if redact {
+			jsonData = []byte(redactLog(string(jsonData)))
+		}
+
+		sectionWriter, err := createZipFile(zipWriter, section.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := sectionWriter.Write(jsonData); err != nil {
+			return nil, err
+		}
+	}
+
+	logTailWriter, err := createZipFile(zipWriter, "log-tail.txt")
+	if err != nil {
+		return nil, err
+	}
+	if err := clt.logHandler.tail.write(logTailWriter, redact); err != nil {
+		return nil, err
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
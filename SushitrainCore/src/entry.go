@@ -6,13 +6,18 @@
 package sushitrain
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/syncthing/syncthing/lib/config"
@@ -32,6 +37,9 @@ type DownloadDelegate interface {
 	OnError(error string)
 	OnFinished(path string)
 	OnProgress(fraction float64)
+	// Called with true when no data could be received for a while because no peer can currently provide it (the
+	// download keeps waiting for a source), and with false when the download continues.
+	OnStalled(stalled bool)
 	IsCancelled() bool
 }
 
@@ -76,21 +84,53 @@ func (entry *Entry) SymlinkTarget() string {
 	return string(entry.info.SymlinkTarget)
 }
 
+// Returns the entry the symlink points to. Returns nil when the target is absolute or points outside the folder (see
+// IsSymlinkTargetInFolder).
 func (entry *Entry) SymlinkTargetEntry() (*Entry, error) {
 	if !entry.info.IsSymlink() {
 		return nil, errors.New("entry is not a symlink")
 	}
-	target := string(entry.info.SymlinkTarget)
-	if !filepath.IsAbs(target) {
-		target = filepath.Join(entry.info.Name, "..", target)
+	target, ok := resolveSymlinkTarget(entry.info.Name, string(entry.info.SymlinkTarget))
+	if !ok {
+		return nil, nil
 	}
 	return entry.Folder.GetFileInformation(target)
 }
 
+// Returns true when the entry is a symlink whose target resolves to a path inside the folder. Symlinks with absolute
+// targets or targets that point outside the folder cannot be followed safely.
+func (entry *Entry) IsSymlinkTargetInFolder() bool {
+	if !entry.info.IsSymlink() {
+		return false
+	}
+	_, ok := resolveSymlinkTarget(entry.info.Name, string(entry.info.SymlinkTarget))
+	return ok
+}
+
+// Resolves a symlink target relative to the directory containing the link. Returns the resulting path in the folder,
+// or false when the target is absolute or points outside the folder.
+func resolveSymlinkTarget(linkPath string, target string) (string, bool) {
+	if target == "" || filepath.IsAbs(target) {
+		return "", false
+	}
+
+	resolved := filepath.Join(filepath.Dir(linkPath), target)
+	if !filepath.IsLocal(resolved) {
+		return "", false
+	}
+	return filepath.ToSlash(resolved), true
+}
+
 func (entry *Entry) Size() int64 {
 	return entry.info.Size
 }
 
+// Suggests how many bytes of blocks should be cached to smoothly stream this file, reading ahead for the specified
+// number of seconds at the specified bitrate
+func (entry *Entry) RecommendedCacheBytes(readAheadSeconds float64, bitrateBytesPerSec int64) int64 {
+	return recommendedCacheBytes(entry.info.Size, int64(entry.info.BlockSize()), readAheadSeconds, bitrateBytesPerSec)
+}
+
 func (entry *Entry) RecursiveSize() (int64, error) {
 	if !entry.IsDirectory() {
 		return entry.Size(), nil
@@ -111,6 +151,41 @@ func (entry *Entry) RecursiveSize() (int64, error) {
 	return size, err
 }
 
+type EntrySelectionSize struct {
+	Bytes int64
+	Files int64
+}
+
+// Returns the total size and number of files that selecting this entry would cover: for a directory, all files below
+// it in the global index (recursively), for a file, the file itself. Files that are already present locally are also
+// counted (see Folder.SelectionDownloadCost to leave these out).
+func (entry *Entry) SelectionSize() (*EntrySelectionSize, error) {
+	if entry.IsDeleted() {
+		return &EntrySelectionSize{}, nil
+	}
+	if !entry.IsDirectory() {
+		return &EntrySelectionSize{Bytes: entry.Size(), Files: 1}, nil
+	}
+
+	leaves, err := entry.Folder.listEntries(entry.Path()+"/", false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	size := &EntrySelectionSize{}
+	err = walkEntries(entry.Path(), leaves, func(leafPrefix string, leaf *model.TreeEntry) (bool, error) {
+		if leaf.Type == protocol.FileInfoTypeFile.String() {
+			size.Bytes += leaf.Size
+			size.Files += 1
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return size, nil
+}
+
 func (entry *Entry) IsDeleted() bool {
 	return entry.info.IsDeleted()
 }
@@ -140,6 +215,11 @@ func (entry *Entry) BlocksHash() string {
 	return base64.StdEncoding.EncodeToString(entry.info.BlocksHash)
 }
 
+// Returns the permission bits of the file as recorded in the index, and false when the index does not record any
+func permissionsMode(info protocol.FileInfo) (fs.FileMode, bool) {
+	return fs.FileMode(info.Permissions & 0o777), !info.NoPermissions
+}
+
 // Creates a subdirectory locally (including intermediate directories) so files can be placed in it, in selectively synced folders
 func (entry *Entry) MaterializeSubdirectory() error {
 	fc := entry.Folder.folderConfiguration()
@@ -157,8 +237,8 @@ func (entry *Entry) MaterializeSubdirectory() error {
 
 	ffs := fc.Filesystem()
 	nativeFilename := osutil.NativeFilename(entry.info.FileName())
-	mode := fs.FileMode(entry.info.Permissions & 0o777)
-	if fc.IgnorePerms || entry.info.NoPermissions {
+	mode, hasMode := permissionsMode(entry.info)
+	if fc.IgnorePerms || !hasMode {
 		mode = 0o777
 	}
 	err := ffs.MkdirAll(nativeFilename, mode)
@@ -224,6 +304,33 @@ func (entry *Entry) IsLocallyPresent() bool {
 	return err == nil
 }
 
+// Returns true when the item exists locally but is of a different type than globally (i.e. a file locally and a
+// directory globally, or vice versa). Syncthing cannot resolve these by itself and will keep reporting errors.
+func (entry *Entry) HasTypeConflict() (bool, error) {
+	if entry.IsDeleted() || entry.IsSymlink() {
+		return false, nil
+	}
+
+	ffs, err := entry.Folder.filesystem()
+	if err != nil {
+		return false, err
+	}
+
+	nativeFilename := osutil.NativeFilename(entry.info.FileName())
+	stat, err := ffs.Lstat(nativeFilename)
+	if err != nil {
+		if fs.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if stat.IsSymlink() {
+		return false, nil
+	}
+	return stat.IsDir() != entry.IsDirectory(), nil
+}
+
 // For non-selective folders, this will return true when not ignored
 func (entry *Entry) IsSelected() bool {
 	matcher, err := entry.Folder.loadIgnores()
@@ -365,6 +472,244 @@ func (entry *Entry) PeersWithFullCopy() (*ListOfStrings, error) {
 	return List(devices), nil
 }
 
+type SourceDiversity struct {
+	Peers         int // Connected peers that can provide at least part of the file
+	FullCopyPeers int // Connected peers that can provide the file in full
+}
+
+func sourceDiversityFrom(blocksPerDevice map[protocol.DeviceID]int, blockCount int, isConnected func(protocol.DeviceID) bool) *SourceDiversity {
+	diversity := &SourceDiversity{}
+	for deviceID, blocksOnDevice := range blocksPerDevice {
+		if blocksOnDevice == 0 || !isConnected(deviceID) {
+			continue
+		}
+		diversity.Peers += 1
+		if blocksOnDevice >= blockCount {
+			diversity.FullCopyPeers += 1
+		}
+	}
+	return diversity
+}
+
+// Returns how many connected peers can currently provide (part of) this file, and how many of them have a full copy
+func (entry *Entry) SourceDiversity() (*SourceDiversity, error) {
+	if entry.Folder.client.app == nil || entry.Folder.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+	if entry.IsDeleted() || entry.IsDirectory() || entry.IsSymlink() {
+		return nil, errors.New("entry is not a file")
+	}
+
+	blocksPerDevice, blockCount, err := entry.availabilityPerDevice()
+	if err != nil {
+		return nil, err
+	}
+	return sourceDiversityFrom(blocksPerDevice, blockCount, entry.Folder.client.app.Internals.IsConnectedTo), nil
+}
+
+// Adds a file of which the device has the specified number of blocks to the completion
+func (c *Completion) addFile(size int64, blockCount int, blocksOnDevice int) {
+	c.GlobalItems += 1
+	c.GlobalBytes += size
+	if blocksOnDevice < blockCount {
+		c.NeedItems += 1
+		c.NeedBytes += size * int64(blockCount-blocksOnDevice) / int64(blockCount)
+	}
+}
+
+func (c *Completion) updatePercentage() {
+	if c.GlobalBytes == 0 {
+		c.CompletionPct = 100
+	} else {
+		c.CompletionPct = 100 * float64(c.GlobalBytes-c.NeedBytes) / float64(c.GlobalBytes)
+	}
+}
+
+/*
+* Returns the completion of this file, or of all files below this directory, for the specified device, based on which
+blocks the device currently announces having. As block availability is only known for connected peers, files are
+reported as needed in full by a peer that is not connected. Deletions are not taken into account.
+*/
+func (entry *Entry) CompletionForDevice(deviceID string) (*Completion, error) {
+	if entry.Folder.client.app == nil || entry.Folder.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+	if entry.IsDeleted() || entry.IsSymlink() {
+		return nil, errors.New("entry is not a directory or file")
+	}
+
+	devID, err := protocol.DeviceIDFromString(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	completion := &Completion{}
+	addEntry := func(fileEntry *Entry) error {
+		blocksPerDevice, blockCount, err := fileEntry.availabilityPerDevice()
+		if err != nil {
+			return err
+		}
+		completion.addFile(fileEntry.Size(), blockCount, blocksPerDevice[devID])
+		return nil
+	}
+
+	if !entry.IsDirectory() {
+		if err := addEntry(entry); err != nil {
+			return nil, err
+		}
+		completion.updatePercentage()
+		return completion, nil
+	}
+
+	leaves, err := entry.Folder.listEntries(entry.Path()+"/", false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkEntries(entry.Path(), leaves, func(leafPrefix string, leaf *model.TreeEntry) (bool, error) {
+		if leaf.Type != protocol.FileInfoTypeFile.String() {
+			return true, nil
+		}
+
+		if entry.Folder.client.isExtraneousIgnored(leaf.Name) {
+			return true, nil
+		}
+
+		leafEntry, err := entry.Folder.GetFileInformation(leafPrefix + "/" + leaf.Name)
+		if err != nil {
+			return false, err
+		}
+		if leafEntry == nil || leafEntry.IsDeleted() {
+			return true, nil
+		}
+		return true, addEntry(leafEntry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	completion.updatePercentage()
+	return completion, nil
+}
+
+// Returns, for each block of the file, which devices have it and how many connected peers can currently provide it. Both
+// BlockAvailabilityMapJSON and Availability are derived from this, so that they always agree.
+func (entry *Entry) blockAvailabilityMap() (protocol.FileInfo, []BlockAvailability, error) {
+	if entry.Folder.client.app == nil || entry.Folder.client.app.Internals == nil {
+		return protocol.FileInfo{}, nil, ErrStillLoading
+	}
+
+	m := entry.Folder.client.app.Internals
+	folderID := entry.Folder.FolderID
+
+	info, ok, err := m.GlobalFileInfo(folderID, entry.info.FileName())
+	if err != nil {
+		return protocol.FileInfo{}, nil, err
+	}
+	if !ok {
+		return protocol.FileInfo{}, nil, errors.New("file not found globally")
+	}
+
+	blocks := make([]BlockAvailability, len(info.Blocks))
+	for blockIndex, block := range info.Blocks {
+		avs, err := m.BlockAvailability(folderID, info, block)
+		if err != nil {
+			return protocol.FileInfo{}, nil, err
+		}
+
+		blockAvailability := BlockAvailability{DeviceIDs: make([]string, 0, len(avs))}
+		peers := make(map[protocol.DeviceID]bool)
+		for _, av := range avs {
+			blockAvailability.DeviceIDs = append(blockAvailability.DeviceIDs, av.ID.String())
+			if av.FromTemporary {
+				blockAvailability.FromTemporary = true
+			}
+			if m.IsConnectedTo(av.ID) {
+				peers[av.ID] = true
+			}
+		}
+		blockAvailability.reachablePeers = len(peers)
+		blockAvailability.Reachable = blockAvailability.reachablePeers > 0
+		blocks[blockIndex] = blockAvailability
+	}
+	return info, blocks, nil
+}
+
+// JSON array with, for each block of the file, the number of connected peers that can currently provide it
+func (entry *Entry) BlockAvailabilityMapJSON() ([]byte, error) {
+	_, blocks, err := entry.blockAvailabilityMap()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int, len(blocks))
+	for blockIndex, block := range blocks {
+		counts[blockIndex] = block.reachablePeers
+	}
+	return json.Marshal(counts)
+}
+
+type BlockAvailability struct {
+	DeviceIDs     []string `json:"deviceIDs"`     // Devices that announced having this block
+	Reachable     bool     `json:"reachable"`     // True when at least one of the devices is currently connected
+	FromTemporary bool     `json:"fromTemporary"` // True when some device only has this block in a temporary file
+
+	reachablePeers int // Number of distinct connected devices that have this block
+}
+
+type AvailabilityReport struct {
+	BlockCount         int
+	BlockSize          int
+	IsLocallyAvailable bool // When true, the file can be read in full without any peers
+	blocks             []BlockAvailability
+}
+
+func (report *AvailabilityReport) DevicesForBlock(blockIndex int) *ListOfStrings {
+	return List(report.blocks[blockIndex].DeviceIDs)
+}
+
+func (report *AvailabilityReport) IsBlockReachable(blockIndex int) bool {
+	return report.IsLocallyAvailable || report.blocks[blockIndex].Reachable
+}
+
+func (report *AvailabilityReport) IsBlockFromTemporary(blockIndex int) bool {
+	return report.blocks[blockIndex].FromTemporary
+}
+
+// Returns true when every block can currently be obtained, either locally or from a connected peer
+func (report *AvailabilityReport) IsFullyReachable() bool {
+	for blockIndex := range report.blocks {
+		if !report.IsBlockReachable(blockIndex) {
+			return false
+		}
+	}
+	return true
+}
+
+func (report *AvailabilityReport) JSON() ([]byte, error) {
+	return json.Marshal(struct {
+		BlockCount         int
+		BlockSize          int
+		IsLocallyAvailable bool
+		Blocks             []BlockAvailability
+	}{report.BlockCount, report.BlockSize, report.IsLocallyAvailable, report.blocks})
+}
+
+// Returns, for each block of the file, which devices have it
+func (entry *Entry) Availability() (*AvailabilityReport, error) {
+	info, blocks, err := entry.blockAvailabilityMap()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AvailabilityReport{
+		BlockCount:         len(info.Blocks),
+		BlockSize:          info.BlockSize(),
+		IsLocallyAvailable: entry.IsLocallyPresent(),
+		blocks:             blocks,
+	}, nil
+}
+
 func (entry *Entry) availabilityPerDevice() (map[protocol.DeviceID]int, int, error) {
 	m := entry.Folder.client.app.Internals
 	folderID := entry.Folder.FolderID
@@ -418,18 +763,102 @@ func (pw *progressWriter) Write(buf []byte) (n int, err error) {
 	return
 }
 
-/** Download this entry to the specific location (should be outside the synced folder!) **/
+// Download this entry to the specific location (should be outside the synced folder!). When toPath is empty, the entry
+// is downloaded to the client's default export directory under its original file name.
 func (entry *Entry) Download(toPath string, delegate DownloadDelegate) {
+	entry.download(toPath, delegate, false)
+}
+
+/*
+* Like Download, but after each file has been downloaded, it is read back and its contents are checked against the block
+hashes. When verification fails, the file is removed and an error is reported to the delegate.
+*/
+func (entry *Entry) DownloadVerified(toPath string, delegate DownloadDelegate) {
+	entry.download(toPath, delegate, true)
+}
+
+/*
+* Like Download, but afterwards the permission bits of the downloaded file (or the files in the downloaded directory)
+are set to the permissions recorded in the index, regardless of whether the folder ignores permissions. Files for which
+the index records no permissions are left alone. On filesystems that do not support modes this has no effect. *
+*/
+func (entry *Entry) DownloadPreservingMode(toPath string, delegate DownloadDelegate) {
+	entry.download(toPath, &modePreservingDownloadDelegate{DownloadDelegate: delegate, entry: entry}, false)
+}
+
+// Sets the permission bits of the downloaded entry (and its contents) before reporting that the download finished
+type modePreservingDownloadDelegate struct {
+	DownloadDelegate
+	entry *Entry
+}
+
+func (m *modePreservingDownloadDelegate) OnFinished(toPath string) {
+	if err := m.entry.applyPermissions(toPath); err != nil {
+		m.DownloadDelegate.OnError(err.Error())
+		return
+	}
+	m.DownloadDelegate.OnFinished(toPath)
+}
+
+// Sets the permission bits of the file at toPath (and for directories, of the files in it) to those in the index
+func (entry *Entry) applyPermissions(toPath string) error {
+	if entry.IsDirectory() {
+		myPrefix := entry.Path() + "/"
+		containedPaths, err := entry.Folder.List(myPrefix, false, true)
+		if err != nil {
+			return err
+		}
+
+		// Children first, so that a directory losing its write permission does not prevent changing its contents
+		for _, containedPath := range slices.Backward(containedPaths.data) {
+			subEntry, err := entry.Folder.GetFileInformation(myPrefix + containedPath)
+			if err != nil {
+				return err
+			}
+			if subEntry == nil || subEntry.IsDeleted() {
+				continue
+			}
+			if mode, ok := permissionsMode(subEntry.info); ok {
+				if err := os.Chmod(path.Join(toPath, containedPath), os.FileMode(mode)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if mode, ok := permissionsMode(entry.info); ok {
+		return os.Chmod(toPath, os.FileMode(mode))
+	}
+	return nil
+}
+
+func (entry *Entry) download(toPath string, delegate DownloadDelegate, verify bool) {
+	entry.Folder.client.recordAccess(entry.Folder.FolderID, entry.Path())
+
 	go func() {
+		if toPath == "" {
+			exportDir := entry.Folder.client.DefaultExportDirectory()
+			if err := os.MkdirAll(exportDir, 0o700); err != nil {
+				delegate.OnError(err.Error())
+				return
+			}
+			exportPath, err := uniqueExportPath(exportDir, entry.FileName())
+			if err != nil {
+				delegate.OnError(err.Error())
+				return
+			}
+			toPath = exportPath
+		}
+
 		if entry.IsDirectory() {
-			entry.downloadDirectory(toPath, delegate)
+			entry.downloadDirectory(toPath, delegate, verify)
 		} else {
-			entry.downloadFile(toPath, delegate)
+			entry.downloadFile(toPath, delegate, verify)
 		}
 	}()
 }
 
-func (entry *Entry) downloadDirectory(toPath string, delegate DownloadDelegate) {
+func (entry *Entry) downloadDirectory(toPath string, delegate DownloadDelegate, verify bool) {
 	go func() {
 		myPrefix := entry.Path() + "/"
 		slog.Info("downloadDirectory", "toPath", toPath, "prefix", myPrefix)
@@ -494,7 +923,7 @@ func (entry *Entry) downloadDirectory(toPath string, delegate DownloadDelegate)
 					delegate.OnProgress((float64(containedFileIndex) + fraction) * perFileFraction)
 				},
 			}
-			subEntry.downloadFile(subEntryToPath, subDelegate)
+			subEntry.downloadFile(subEntryToPath, subDelegate, verify)
 			if failed {
 				return
 			}
@@ -528,11 +957,70 @@ func (s *subDownloadDelegate) OnProgress(fraction float64) {
 	s.progressCallback(fraction)
 }
 
+func (s *subDownloadDelegate) OnStalled(stalled bool) {
+	s.parent.OnStalled(stalled)
+}
+
 var _ DownloadDelegate = &subDownloadDelegate{}
 
+// Like Download, but when a partially downloaded file exists at toPath, only the remainder is fetched. The partial data
+// is verified against the block hashes of the file; downloading resumes after the last intact block.
+func (entry *Entry) DownloadResumable(toPath string, delegate DownloadDelegate) {
+	if toPath == "" || entry.IsDirectory() {
+		entry.Download(toPath, delegate)
+		return
+	}
+
+	entry.Folder.client.recordAccess(entry.Folder.FolderID, entry.Path())
+	go entry.downloadFileFrom(toPath, delegate, true, false)
+}
+
+// Returns the index of the first block that is not present (intact) in the partial file
+func resumableBlockIndex(partial io.ReaderAt, partialSize int64, info protocol.FileInfo) int {
+	for blockIndex, block := range info.Blocks {
+		if block.Offset+int64(block.Size) > partialSize {
+			return blockIndex
+		}
+
+		buf := make([]byte, block.Size)
+		if _, err := partial.ReadAt(buf, block.Offset); err != nil {
+			return blockIndex
+		}
+
+		hash := sha256.Sum256(buf)
+		if !bytes.Equal(hash[:], block.Hash) {
+			return blockIndex
+		}
+	}
+	return len(info.Blocks)
+}
+
 /** Download this file to the specific location (should be outside the synced folder!) **/
-func (entry *Entry) downloadFile(toPath string, delegate DownloadDelegate) {
+func (entry *Entry) downloadFile(toPath string, delegate DownloadDelegate, verify bool) {
+	entry.downloadFileFrom(toPath, delegate, false, verify)
+}
+
+// Checks that the file has the expected size and that each of its blocks matches its hash
+func verifyDownloadedFile(file io.ReaderAt, size int64, info protocol.FileInfo) error {
+	if size != info.Size {
+		return fmt.Errorf("downloaded file has size %d, expected %d", size, info.Size)
+	}
+	if blockIndex := resumableBlockIndex(file, size, info); blockIndex < len(info.Blocks) {
+		return fmt.Errorf("downloaded file is corrupt: block %d of %d does not match its hash", blockIndex+1, len(info.Blocks))
+	}
+	return nil
+}
+
+func (entry *Entry) downloadFileFrom(toPath string, delegate DownloadDelegate, resume bool, verify bool) {
+	ffs := fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Dir(toPath))
+	entry.downloadFileInto(ffs, filepath.Base(toPath), delegate, resume, verify)
+}
+
+// Downloads the file to the file with the specified name in the filesystem. This allows writing into a folder through
+// its (possibly custom) filesystem.
+func (entry *Entry) downloadFileInto(ffs fs.Filesystem, toName string, delegate DownloadDelegate, resume bool, verify bool) {
 	context := context.WithoutCancel(context.Background())
+	toPath := filepath.Join(ffs.URI(), toName)
 	m := entry.Folder.client.app.Internals
 	folderID := entry.Folder.FolderID
 	info, ok, err := m.GlobalFileInfo(folderID, entry.info.FileName())
@@ -547,7 +1035,11 @@ func (entry *Entry) downloadFile(toPath string, delegate DownloadDelegate) {
 	}
 
 	// Create file to download to
-	outFile, err := os.Create(toPath)
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flags = os.O_RDWR | os.O_CREATE
+	}
+	outFile, err := ffs.OpenFile(toName, flags, 0o666)
 	if err != nil {
 		delegate.OnError("could not open file for downloading to: " + err.Error())
 		return
@@ -559,20 +1051,69 @@ func (entry *Entry) downloadFile(toPath string, delegate DownloadDelegate) {
 		}
 	}()
 
-	delegate.OnProgress(0.0)
+	// Find out where to continue a previous download
+	startBlock := 0
+	var startOffset int64 = 0
+	if resume {
+		stat, err := outFile.Stat()
+		if err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+
+		startBlock = resumableBlockIndex(outFile, stat.Size(), info)
+		startOffset = info.Size
+		if startBlock < len(info.Blocks) {
+			startOffset = info.Blocks[startBlock].Offset
+		}
+		slog.Info("resuming download", "path", toPath, "partialSize", stat.Size(), "startBlock", startBlock, "startOffset", startOffset)
+
+		// Discard anything after the intact blocks
+		if err := outFile.Truncate(startOffset); err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+		if _, err := outFile.Seek(startOffset, io.SeekStart); err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+	}
+
 	mp := newMiniPuller(entry.Folder.client.Measurements, m)
+	mp.onStalled = delegate.OnStalled
 	pw := progressWriter{
 		out:      outFile,
 		delegate: delegate,
 		total:    int(info.Size),
-		written:  0,
+		written:  int(startOffset),
+	}
+	if info.Size > 0 {
+		delegate.OnProgress(float64(startOffset) / float64(info.Size))
+	} else {
+		delegate.OnProgress(0.0)
 	}
 
-	err = mp.downloadInto(context, &pw, folderID, info)
+	err = mp.downloadIntoFrom(context, &pw, folderID, info, startBlock)
 	if err != nil {
 		delegate.OnError(err.Error())
 		return
 	}
+
+	if verify {
+		stat, err := outFile.Stat()
+		if err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+		if err := verifyDownloadedFile(outFile, stat.Size(), info); err != nil {
+			slog.Warn("downloaded file failed verification, removing", "path", toPath, "cause", err)
+			if err := ffs.Remove(toName); err != nil {
+				slog.Warn("could not remove file that failed verification", "path", toPath, "cause", err)
+			}
+			delegate.OnError(err.Error())
+			return
+		}
+	}
 	delegate.OnFinished(toPath)
 }
 
@@ -585,11 +1126,32 @@ func (entry *Entry) OnDemandURL() string {
 	return server.urlFor(entry.Folder.FolderID, entry.info.FileName())
 }
 
+// Returns a URL at which the entry is served converted to a natively playable format by the streaming server's
+// Transcoder. When the entry does not need to be converted (or no transcoder is set), the original contents are served.
+func (entry *Entry) TranscodingURL() string {
+	server := entry.Folder.client.Server
+	if server == nil {
+		return ""
+	}
+
+	return server.transcodingURLFor(entry.Folder.FolderID, entry.info.FileName())
+}
+
 func (entry *Entry) Extension() string {
 	return filepath.Ext(entry.info.FileName())
 }
 
+// Returns true when the name and contents of this entry are encrypted (see Folder.IsEncryptedAtRest)
+func (entry *Entry) IsEncrypted() bool {
+	return entry.Folder.IsEncryptedAtRest()
+}
+
+// Returns the MIME type based on the extension of the entry. Encrypted entries never have a MIME type, as their contents
+// cannot be rendered.
 func (entry *Entry) MIMEType() string {
+	if entry.IsEncrypted() {
+		return ""
+	}
 	ext := filepath.Ext(entry.info.FileName())
 	return MIMETypeForExtension(ext)
 }
@@ -0,0 +1,121 @@
+package sushitrain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestResolveSymlinkTarget(t *testing.T) {
+	cases := []struct {
+		linkPath string
+		target   string
+		resolved string
+		ok       bool
+	}{
+		{"a/link", "b.txt", "a/b.txt", true},
+		{"a/link", "../b.txt", "b.txt", true},
+		{"a/b/link", "../../c/d", "c/d", true},
+		{"link", "../outside", "", false},
+		{"a/link", "../../outside", "", false},
+		{"a/link", "/etc/passwd", "", false},
+		{"a/link", "", "", false},
+	}
+
+	for _, c := range cases {
+		resolved, ok := resolveSymlinkTarget(c.linkPath, c.target)
+		if resolved != c.resolved || ok != c.ok {
+			t.Errorf("resolving %q from %q: got (%q, %t), expected (%q, %t)", c.target, c.linkPath, resolved, ok, c.resolved, c.ok)
+		}
+	}
+}
+
+func TestVerifyDownloadedFile(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), protocol.MinBlockSize/5)
+	info := protocol.FileInfo{Name: "file.bin", Size: int64(len(data)), RawBlockSize: int32(protocol.MinBlockSize)}
+	for offset := 0; offset < len(data); offset += protocol.MinBlockSize {
+		end := min(offset+protocol.MinBlockSize, len(data))
+		hash := sha256.Sum256(data[offset:end])
+		info.Blocks = append(info.Blocks, protocol.BlockInfo{Offset: int64(offset), Size: end - offset, Hash: hash[:]})
+	}
+
+	if err := verifyDownloadedFile(bytes.NewReader(data), int64(len(data)), info); err != nil {
+		t.Errorf("intact file failed verification: %v", err)
+	}
+
+	corrupt := bytes.Clone(data)
+	corrupt[protocol.MinBlockSize+10] ^= 0xFF
+	if err := verifyDownloadedFile(bytes.NewReader(corrupt), int64(len(corrupt)), info); err == nil {
+		t.Error("corrupt file passed verification")
+	}
+
+	truncated := data[:len(data)-1]
+	if err := verifyDownloadedFile(bytes.NewReader(truncated), int64(len(truncated)), info); err == nil {
+		t.Error("truncated file passed verification")
+	}
+}
+
+func TestSourceDiversityFrom(t *testing.T) {
+	full := protocol.DeviceID{1}
+	partial := protocol.DeviceID{2}
+	disconnected := protocol.DeviceID{3}
+	blocksPerDevice := map[protocol.DeviceID]int{full: 4, partial: 2, disconnected: 4}
+	isConnected := func(deviceID protocol.DeviceID) bool {
+		return deviceID != disconnected
+	}
+
+	diversity := sourceDiversityFrom(blocksPerDevice, 4, isConnected)
+	if diversity.Peers != 2 || diversity.FullCopyPeers != 1 {
+		t.Errorf("unexpected diversity: %+v", diversity)
+	}
+}
+
+func TestCompletionAddFile(t *testing.T) {
+	completion := &Completion{}
+	completion.addFile(400, 4, 4)
+	completion.addFile(400, 4, 1)
+	completion.addFile(0, 0, 0)
+	completion.updatePercentage()
+
+	if completion.GlobalItems != 3 || completion.NeedItems != 1 {
+		t.Errorf("unexpected item counts: %+v", completion)
+	}
+	if completion.GlobalBytes != 800 || completion.NeedBytes != 300 || completion.CompletionPct != 62.5 {
+		t.Errorf("unexpected byte counts: %+v", completion)
+	}
+
+	empty := &Completion{}
+	empty.updatePercentage()
+	if empty.CompletionPct != 100 {
+		t.Errorf("expected empty subtree to be complete, got %f", empty.CompletionPct)
+	}
+}
+
+func TestApplyPermissions(t *testing.T) {
+	toPath := filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(toPath, []byte("#!/bin/sh\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &Entry{info: protocol.FileInfo{Name: "script.sh", Type: protocol.FileInfoTypeFile, Permissions: 0o755}}
+	if err := entry.applyPermissions(toPath); err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := os.Stat(toPath); err != nil || stat.Mode().Perm() != 0o755 {
+		t.Errorf("expected mode 0755: %v, %v", stat.Mode(), err)
+	}
+
+	// Files without recorded permissions are left alone
+	entry.info.Permissions = 0o600
+	entry.info.NoPermissions = true
+	if err := entry.applyPermissions(toPath); err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := os.Stat(toPath); err != nil || stat.Mode().Perm() != 0o755 {
+		t.Errorf("expected mode to be unchanged: %v, %v", stat.Mode(), err)
+	}
+}
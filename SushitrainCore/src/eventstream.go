@@ -0,0 +1,72 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"golang.org/x/exp/slog"
+)
+
+type EventStreamDelegate interface {
+	// Called for each event with the event type (e.g. "FolderCompletion") and the JSON-encoded event (which includes
+	// the event ID, time and type-specific data)
+	OnEvent(eventType string, data []byte)
+}
+
+type EventSubscription struct {
+	cancel context.CancelFunc
+}
+
+// Stop delivering events to the delegate
+func (sub *EventSubscription) Cancel() {
+	sub.cancel()
+}
+
+// Subscribe to the raw Syncthing event stream, for diagnostic purposes. When types is nil or empty, all events are
+// delivered. Events are delivered from a background goroutine until the subscription is cancelled or the client stops.
+func (clt *Client) SubscribeEvents(types *ListOfStrings, delegate EventStreamDelegate) (*EventSubscription, error) {
+	if clt.evLogger == nil {
+		return nil, ErrStillLoading
+	}
+
+	var mask events.EventType = events.AllEvents
+	if types != nil && len(types.data) > 0 {
+		mask = 0
+		for _, typeName := range types.data {
+			eventType := events.UnmarshalEventType(typeName)
+			if eventType == 0 {
+				return nil, fmt.Errorf("unknown event type: %s", typeName)
+			}
+			mask |= eventType
+		}
+	}
+
+	ctx, cancel := context.WithCancel(clt.ctx)
+	evSub := clt.evLogger.Subscribe(mask)
+
+	go func() {
+		defer evSub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-evSub.C():
+				data, err := json.Marshal(evt)
+				if err != nil {
+					slog.Warn("could not encode event", "type", evt.Type.String(), "cause", err)
+					continue
+				}
+				delegate.OnEvent(evt.Type.String(), data)
+			}
+		}
+	}()
+
+	return &EventSubscription{cancel: cancel}, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/model"
+)
+
+const (
+	FailedItemKindPermission  = "permission"
+	FailedItemKindDiskFull    = "diskFull"
+	FailedItemKindUnavailable = "unavailable"
+	FailedItemKindOther       = "other"
+)
+
+type FailedItem struct {
+	Path  string
+	Error string
+	Kind  string // One of the FailedItemKind* constants
+}
+
+// Classifies the error message Syncthing reported for an item that could not be synced
+func failedItemKind(message string) string {
+	message = strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "permission denied"), strings.Contains(message, "operation not permitted"):
+		return FailedItemKindPermission
+	case strings.Contains(message, "no space left"), strings.Contains(message, "insufficient space"):
+		return FailedItemKindDiskFull
+	case strings.Contains(message, "no connected device"), strings.Contains(message, "peers who had this file went away"):
+		return FailedItemKindUnavailable
+	default:
+		return FailedItemKindOther
+	}
+}
+
+type FailedItemList struct {
+	items []*FailedItem
+}
+
+func (lst *FailedItemList) Count() int {
+	return len(lst.items)
+}
+
+func (lst *FailedItemList) ItemAt(index int) *FailedItem {
+	return lst.items[index]
+}
+
+// Called for FolderErrors events, which Syncthing sends after a pull in which items failed
+func (clt *Client) setFolderErrors(folderID string, errors []model.FileError) {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	clt.folderErrors[folderID] = slices.Clone(errors)
+}
+
+// Called for FolderSummary events, to forget about errors that were resolved (no event is sent for a successful pull)
+func (clt *Client) clearFolderErrorsIfResolved(folderID string, errorCount int) {
+	if errorCount > 0 {
+		return
+	}
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	delete(clt.folderErrors, folderID)
+}
+
+// Returns the items that failed to sync during the last pull, with the reason reported by Syncthing. Failed items are
+// retried on the next pull.
+func (fld *Folder) FailedItems() (*FailedItemList, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	fld.client.mutex.Lock()
+	errors := fld.client.folderErrors[fld.FolderID]
+	fld.client.mutex.Unlock()
+
+	items := Map(errors, func(fe model.FileError) *FailedItem {
+		return &FailedItem{Path: fe.Path, Error: fe.Err, Kind: failedItemKind(fe.Err)}
+	})
+	return &FailedItemList{items: items}, nil
+}
@@ -0,0 +1,37 @@
+package sushitrain
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/model"
+)
+
+func TestFailedItemKind(t *testing.T) {
+	cases := map[string]string{
+		"open /x/.syncthing.a.tmp: permission denied":                 FailedItemKindPermission,
+		"insufficient space in folder Photos (/x): current 1MB < 2MB": FailedItemKindDiskFull,
+		"write /x: no space left on device":                           FailedItemKindDiskFull,
+		"no connected device has the required version of this file":   FailedItemKindUnavailable,
+		"file modified but not rescanned; will try again later":       FailedItemKindOther,
+	}
+	for message, expected := range cases {
+		if kind := failedItemKind(message); kind != expected {
+			t.Errorf("%q: expected %s, got %s", message, expected, kind)
+		}
+	}
+}
+
+func TestFolderErrorsResolved(t *testing.T) {
+	clt := &Client{folderErrors: make(map[string][]model.FileError)}
+	clt.setFolderErrors("a", []model.FileError{{Path: "x.jpg", Err: "permission denied"}})
+
+	clt.clearFolderErrorsIfResolved("a", 1)
+	if len(clt.folderErrors["a"]) != 1 {
+		t.Fatal("expected errors to be kept while the folder still reports errors")
+	}
+
+	clt.clearFolderErrorsIfResolved("a", 0)
+	if _, ok := clt.folderErrors["a"]; ok {
+		t.Error("expected errors to be cleared")
+	}
+}
@@ -6,16 +6,19 @@
 package sushitrain
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"path"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/fs"
@@ -105,6 +108,28 @@ func (fld *Folder) SetWatcherDelaySeconds(seconds int) error {
 	})
 }
 
+// Returns the order in which files are pulled: "random", "alphabetic", "smallestFirst", "largestFirst", "oldestFirst"
+// or "newestFirst"
+func (fld *Folder) PullOrder() string {
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return ""
+	}
+
+	return fc.Order.String()
+}
+
+func (fld *Folder) SetPullOrder(order string) error {
+	var pullOrder config.PullOrder
+	if err := pullOrder.UnmarshalText([]byte(order)); err != nil || pullOrder.String() != order {
+		return fmt.Errorf("invalid pull order: %q", order)
+	}
+
+	return fld.changeFolderConfiguration(func(config *config.FolderConfiguration) {
+		config.Order = pullOrder
+	})
+}
+
 func (fld *Folder) Unlink() error {
 	fc := fld.folderConfiguration()
 	if fc == nil {
@@ -127,6 +152,144 @@ func (fld *Folder) Unlink() error {
 	return nil
 }
 
+const (
+	resetIndexAddAttempts   = 3
+	resetIndexRetryInterval = time.Second
+)
+
+/*
+Drops the index data of this folder (and only this folder) and rebuilds it from the files on disk, without touching the
+files themselves. Afterwards, the index is exchanged with peers again and any differences are synced as usual.
+
+Syncthing only allows dropping the index of a folder that is not running, and does not expose a way to do so directly.
+Therefore the folder is paused, temporarily removed from the configuration (which stops it and drops its index) and then
+added back with the same configuration, which starts it with an empty index and triggers a full scan. These are separate
+configuration changes: in between, the folder does not exist (e.g. FolderWithID returns nil for it). Adding the folder
+back is retried a few times; if it still fails, the error is returned and the folder has to be added again by the user.
+*/
+func (fld *Folder) ResetIndex() error {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return ErrStillLoading
+	}
+
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return errors.New("folder does not exist")
+	}
+	folderConfig := fc.Copy()
+
+	slog.Info("resetting folder index", "folderID", fld.FolderID)
+
+	// Stop scanning and pulling before the folder is removed
+	if !folderConfig.Paused {
+		if err := fld.SetPaused(true); err != nil {
+			return err
+		}
+	}
+
+	if err := fld.Unlink(); err != nil {
+		if !folderConfig.Paused {
+			if err := fld.SetPaused(false); err != nil {
+				slog.Warn("could not resume folder after failing to reset its index", "folderID", fld.FolderID, "cause", err)
+			}
+		}
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < resetIndexAddAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(resetIndexRetryInterval)
+		}
+
+		err = fld.client.changeConfiguration(func(cfg *config.Configuration) {
+			cfg.SetFolder(folderConfig)
+		})
+		if err == nil {
+			return nil
+		}
+		slog.Warn("could not add folder back after dropping its index", "folderID", fld.FolderID, "attempt", attempt+1, "cause", err)
+	}
+	return fmt.Errorf("could not add folder back after dropping its index: %w", err)
+}
+
+// Moves or renames a file or directory within this folder. In selective folders, the selection is moved along.
+func (fld *Folder) RenameEntry(fromPath string, toPath string) error {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return errNoClient
+	}
+
+	fromPath = strings.Trim(fromPath, "/")
+	toPath = strings.Trim(toPath, "/")
+	if fromPath == "" || toPath == "" {
+		return errors.New("empty path")
+	}
+	if !filepath.IsLocal(fromPath) || !filepath.IsLocal(toPath) {
+		return errors.New("path is not local")
+	}
+	if fromPath == toPath {
+		return nil
+	}
+	if strings.HasPrefix(toPath, fromPath+"/") {
+		return errors.New("cannot move a directory into itself")
+	}
+
+	ffs, err := fld.filesystem()
+	if err != nil {
+		return err
+	}
+
+	nativeFrom := osutil.NativeFilename(fromPath)
+	nativeTo := osutil.NativeFilename(toPath)
+	if _, err := ffs.Lstat(nativeFrom); err != nil {
+		return err
+	}
+	if _, err := ffs.Lstat(nativeTo); err == nil {
+		return fmt.Errorf("a file already exists at '%s'", toPath)
+	}
+	if err := ffs.MkdirAll(filepath.Dir(nativeTo), 0o755); err != nil {
+		return err
+	}
+
+	slog.Info("rename entry", "folderID", fld.FolderID, "from", fromPath, "to", toPath)
+	if err := ffs.Rename(nativeFrom, nativeTo); err != nil {
+		return err
+	}
+
+	if fld.IsSelective() {
+		_, err := fld.changeSelection(func(sel *selection) error {
+			if !sel.isSelectiveIgnore() {
+				return errors.New("folder is not a selective sync folder")
+			}
+			sel.renameSelectedPath(fromPath, toPath)
+			return nil
+		})
+		if err != nil {
+			// The selection is not updated before renaming, because deselecting the old path would remove the files at
+			// that path. Instead, undo the rename so that the files stay selected under their old path.
+			if rollbackErr := ffs.Rename(nativeTo, nativeFrom); rollbackErr != nil {
+				return fmt.Errorf("could not update selection (%w) nor undo the rename: %w", err, rollbackErr)
+			}
+			return err
+		}
+	}
+
+	fromDir := filepath.Dir(fromPath)
+	toDir := filepath.Dir(toPath)
+	if fromDir == "." {
+		fromDir = ""
+	}
+	if toDir == "." {
+		toDir = ""
+	}
+	go func() {
+		if err := fld.client.app.Internals.ScanFolderSubdirs(fld.FolderID, []string{fromDir, toDir}); err != nil {
+			slog.Warn("rescan after rename failed", "folderID", fld.FolderID, "cause", err)
+		}
+	}()
+	return nil
+}
+
 func (fld *Folder) filesystem() (fs.Filesystem, error) {
 	fc := fld.folderConfiguration()
 	if fc == nil {
@@ -154,6 +317,27 @@ func (fld *Folder) Exists() bool {
 	return fld.folderConfiguration() != nil
 }
 
+// Returns the IDs of connected peers that are currently downloading any file in this folder from us
+func (fld *Folder) PeersDownloadingFromUs() *ListOfStrings {
+	clt := fld.client
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	peers := make([]string, 0)
+	for peerID, uploadsPerFolder := range clt.uploadProgress {
+		// Skip peers that are not connected
+		peer := clt.PeerWithID(peerID)
+		if peer == nil || !peer.IsConnected() {
+			continue
+		}
+
+		if len(uploadsPerFolder[fld.FolderID]) > 0 {
+			peers = append(peers, peerID)
+		}
+	}
+	return List(peers)
+}
+
 func (fld *Folder) IsPaused() bool {
 	fc := fld.folderConfiguration()
 	if fc == nil {
@@ -164,9 +348,13 @@ func (fld *Folder) IsPaused() bool {
 }
 
 func (fld *Folder) SetPaused(paused bool) error {
-	return fld.changeFolderConfiguration(func(config *config.FolderConfiguration) {
+	err := fld.changeFolderConfiguration(func(config *config.FolderConfiguration) {
 		config.Paused = paused
 	})
+	if err == nil && !paused {
+		fld.client.cancelTimedPause(fld.FolderID)
+	}
+	return err
 }
 
 func (fld *Folder) IsWatcherEnabled() bool {
@@ -292,6 +480,24 @@ func (fld *Folder) State() (string, error) {
 	return state, err
 }
 
+// Aborts the scan that is currently running for this folder. Syncthing provides no way to stop a scan other than
+// stopping the folder, so the folder is paused, and it stays paused afterwards (this is saved in the configuration, like
+// any other pause). Results of the scan so far are committed to the index in batches, which leaves it consistent. Note
+// that Syncthing starts with a full scan when the folder is resumed using SetPaused(false).
+func (fld *Folder) CancelScan() error {
+	state, err := fld.State()
+	if err != nil {
+		return err
+	}
+
+	if state != model.FolderScanning.String() && state != model.FolderScanWaiting.String() {
+		return errors.New("folder is not scanning")
+	}
+
+	slog.Info("cancelling scan by pausing folder", "folderID", fld.FolderID)
+	return fld.SetPaused(true)
+}
+
 func (fld *Folder) GetFileInformation(path string) (*Entry, error) {
 	if fld.client.app == nil {
 		return nil, nil
@@ -367,6 +573,74 @@ func (fld *Folder) List(prefix string, directories bool, recurse bool) (*ListOfS
 	return List(names), nil
 }
 
+// An item returned by ListEntriesJSON
+type listedEntry struct {
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	IsDir          bool   `json:"isDir"`
+	ModTimeMs      int64  `json:"modTimeMs"`
+	IsDeleted      bool   `json:"isDeleted"` // Always false, as the global tree does not contain deleted items
+	LocallyPresent bool   `json:"locallyPresent"`
+}
+
+// Flattens the tree like flatten, but keeps the metadata of each item. The isPresent function is called with the path
+// of each item (relative to the folder root) to determine whether it is locally present.
+func flattenListedEntries(entries []*model.TreeEntry, recurse bool, prefix string, namePrefix string, isPresent func(path string) bool) []listedEntry {
+	listed := make([]listedEntry, 0, len(entries))
+	for _, entry := range entries {
+		listed = append(listed, listedEntry{
+			Name:           namePrefix + entry.Name,
+			Size:           entry.Size,
+			IsDir:          entry.Type == protocol.FileInfoTypeDirectory.String(),
+			ModTimeMs:      entry.ModTime.UnixMilli(),
+			LocallyPresent: isPresent(prefix + namePrefix + entry.Name),
+		})
+		if recurse {
+			listed = append(listed, flattenListedEntries(entry.Children, recurse, prefix, namePrefix+entry.Name+"/", isPresent)...)
+		}
+	}
+	return listed
+}
+
+// Like List, but returns a JSON array with an object for each item that contains its name (relative to the prefix), size,
+// whether it is a directory, its modification time (in milliseconds since the epoch), whether it is deleted and whether
+// it is present locally. Deleted items are not listed, so isDeleted is always false. The metadata is taken from the
+// global index in one go, which saves looking up each item through GetFileInformation; local presence is still
+// determined by checking for each item on disk.
+func (fld *Folder) ListEntriesJSON(prefix string, recurse bool) ([]byte, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return nil, errors.New("folder does not exist")
+	}
+
+	entries, err := fld.listEntries(prefix, false, recurse)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names in the tree are relative to the prefix, which is treated as a directory
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	// For custom filesystem types, files are never 'locally present' (see Entry.IsLocallyPresent)
+	isBasic := fc.FilesystemType == config.FilesystemTypeBasic || fc.FilesystemType.String() == ""
+	ffs := fc.Filesystem()
+	listed := flattenListedEntries(entries, recurse, prefix, "", func(path string) bool {
+		if !isBasic {
+			return false
+		}
+		_, err := ffs.Lstat(osutil.NativeFilename(path))
+		return err == nil
+	})
+	return json.Marshal(listed)
+}
+
 func (fld *Folder) ShareWithDevice(deviceID string, toggle bool, encryptionPassword string) error {
 	devID, err := protocol.DeviceIDFromString(deviceID)
 	if err != nil {
@@ -399,6 +673,54 @@ func (fld *Folder) ShareWithDevice(deviceID string, toggle bool, encryptionPassw
 	return err
 }
 
+// Adds the devices to the folder configuration, skipping devices the folder is already shared with (their settings are
+// left alone). Returns the devices that were added.
+func addFolderDevices(fc *config.FolderConfiguration, devIDs []protocol.DeviceID, encryptionPassword string) []protocol.DeviceID {
+	added := make([]protocol.DeviceID, 0)
+	for _, devID := range devIDs {
+		if slices.ContainsFunc(fc.Devices, func(fdc config.FolderDeviceConfiguration) bool { return fdc.DeviceID == devID }) {
+			continue
+		}
+		fc.Devices = append(fc.Devices, config.FolderDeviceConfiguration{
+			DeviceID:           devID,
+			EncryptionPassword: encryptionPassword,
+		})
+		added = append(added, devID)
+	}
+	return added
+}
+
+// Shares the folder with all specified devices in a single configuration change. Devices the folder is already shared
+// with are left alone. When any of the device IDs is invalid, nothing is changed.
+func (fld *Folder) ShareWithDevices(deviceIDs *ListOfStrings, encryptionPassword string) error {
+	if deviceIDs == nil {
+		return nil
+	}
+
+	devIDs := make([]protocol.DeviceID, 0, len(deviceIDs.data))
+	for _, deviceID := range deviceIDs.data {
+		devID, err := protocol.DeviceIDFromString(deviceID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", deviceID, err)
+		}
+		devIDs = append(devIDs, devID)
+	}
+
+	if fld.folderConfiguration() == nil {
+		return errors.New("folder does not exist")
+	}
+
+	return fld.client.changeConfiguration(func(cfg *config.Configuration) {
+		fc, _, ok := cfg.Folder(fld.FolderID)
+		if !ok {
+			return
+		}
+		if added := addFolderDevices(&fc, devIDs, encryptionPassword); len(added) > 0 {
+			cfg.SetFolder(fc)
+		}
+	})
+}
+
 func (fld *Folder) sharedWith() ([]protocol.DeviceID, error) {
 	fc := fld.folderConfiguration()
 	if fc == nil {
@@ -454,6 +776,98 @@ func (fld *Folder) EncryptionPasswordFor(peer string) string {
 	return ""
 }
 
+const minEncryptionPasswordLength = 8
+
+var commonEncryptionPasswords = []string{
+	"password", "password1", "passw0rd", "12345678", "123456789", "1234567890", "qwertyui", "qwerty123", "iloveyou",
+	"abcdefgh", "letmein1", "syncthing",
+}
+
+// Returns true when the characters in the password are all the same, or form an ascending or descending sequence
+func isTrivialSequence(password string) bool {
+	runes := []rune(password)
+	if len(runes) < 2 {
+		return true
+	}
+	step := runes[1] - runes[0]
+	if step < -1 || step > 1 {
+		return false
+	}
+	for idx := 2; idx < len(runes); idx++ {
+		if runes[idx]-runes[idx-1] != step {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether the password is suitable for sharing a folder encrypted with an untrusted device. Returns
+// ErrEncryptionPasswordTooShort for passwords that are too short, and ErrEncryptionPasswordWeak for passwords that are
+// trivial to guess (such as "aaaaaaaa", "12345678" or "password").
+func ValidateEncryptionPassword(password string) error {
+	if utf8.RuneCountInString(password) < minEncryptionPasswordLength {
+		return ErrEncryptionPasswordTooShort
+	}
+	if isTrivialSequence(password) || slices.Contains(commonEncryptionPasswords, strings.ToLower(password)) {
+		return ErrEncryptionPasswordWeak
+	}
+	return nil
+}
+
+func IsWeakEncryptionPasswordError(err error) bool {
+	return errors.Is(err, ErrEncryptionPasswordWeak)
+}
+
+func (fld *Folder) IsSharedEncryptedWith(deviceID string) bool {
+	return fld.EncryptionPasswordFor(deviceID) != ""
+}
+
+/*
+Changes the password used to encrypt this folder for the specified (untrusted) device. This is only possible as long as
+the device has not received any data for the folder yet: the device cannot decrypt data encrypted with the new password
+using the old one (and vice versa), so changing the password after the fact would leave it out of sync. In that case
+the folder has to be unshared and shared again with the device, which will then remove its copy.
+*/
+func (fld *Folder) ChangeEncryptionPassword(deviceID string, newPassword string) error {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return ErrStillLoading
+	}
+
+	devID, err := protocol.DeviceIDFromString(deviceID)
+	if err != nil {
+		return err
+	}
+
+	if !fld.IsSharedEncryptedWith(deviceID) {
+		return errors.New("folder is not shared encrypted with this device")
+	}
+
+	if err := ValidateEncryptionPassword(newPassword); err != nil && !IsWeakEncryptionPasswordError(err) {
+		return err
+	}
+
+	completion, err := fld.client.app.Internals.Completion(devID, fld.FolderID)
+	if err != nil {
+		return err
+	}
+	if completion.Sequence > 0 {
+		return errors.New("the device already has data encrypted with the current password; unshare the folder and share it again to use a new password")
+	}
+
+	return fld.client.changeConfiguration(func(cfg *config.Configuration) {
+		fc, _, ok := cfg.Folder(fld.FolderID)
+		if !ok {
+			return
+		}
+		for idx := range fc.Devices {
+			if fc.Devices[idx].DeviceID == devID {
+				fc.Devices[idx].EncryptionPassword = newPassword
+			}
+		}
+		cfg.SetFolder(fc)
+	})
+}
+
 func (fld *Folder) ConnectedPeerCount() int {
 	fc := fld.folderConfiguration()
 	if fc == nil {
@@ -501,39 +915,135 @@ func (fld *Folder) SetLabel(label string) error {
 	})
 }
 
-var (
-	errNoClient             = errors.New("client not started up yet")
-	errFolderConfigNotFound = errors.New("folder config not founnd")
-)
+// Returns all symlinks in the global index of this folder. Use Entry.IsSymlinkTargetInFolder to find out whether their
+// targets can be resolved within the folder.
+func (fld *Folder) Symlinks() (*ListOfEntries, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, errNoClient
+	}
 
-func (fld *Folder) whilePaused(block func() error) error {
-	pausedBefore := fld.IsPaused()
-	if !pausedBefore {
-		err := fld.SetPaused(true)
+	entries := make([]*Entry, 0)
+	for f, err := range zipError(fld.client.app.Internals.AllGlobalFiles(fld.FolderID)) {
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if f.Deleted {
+			continue
+		}
+
+		switch f.Type {
+		case protocol.FileInfoTypeSymlink, protocol.FileInfoTypeSymlinkFile, protocol.FileInfoTypeSymlinkDirectory:
+			entry, err := fld.GetFileInformation(f.Name)
+			if err != nil {
+				return nil, err
+			}
+			if entry != nil {
+				entries = append(entries, entry)
+			}
 		}
-		defer fld.SetPaused(pausedBefore)
 	}
-	return block()
+	return &ListOfEntries{data: entries}, nil
 }
 
-func (fld *Folder) SetSelective(selective bool) error {
-	slog.Info("SetSelective", "folder", fld.FolderID, "selective", selective)
+// Returns the name of the file or directory that marks the folder root as healthy (by default '.stfolder')
+func (fld *Folder) MarkerName() string {
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return ""
+	}
+	return fc.MarkerName
+}
+
+// Marker names must refer to an entry directly inside the folder root
+func isValidMarkerName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\") && filepath.IsLocal(name)
+}
+
+/*
+* Changes the name of the folder marker. The existing marker is moved to the new name, or when it does not exist, a new
+(hidden) marker directory is created, so that Syncthing does not consider the folder unhealthy after the change.
+*/
+func (fld *Folder) SetMarkerName(name string) error {
 	if fld.client.app == nil || fld.client.app.Internals == nil {
 		return errNoClient
 	}
-	fld.cachedIgnore.matcher = nil // Purge our cache
+	if !isValidMarkerName(name) {
+		return errors.New("invalid marker name")
+	}
 
-	return fld.whilePaused(func() error {
-		_, err := fld.changeSelection(func(selection *selection) error {
-			return selection.setSelective(selective)
-		})
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return errFolderConfigNotFound
+	}
+	if fc.MarkerName == name {
+		return nil
+	}
+
+	ffs, err := fld.filesystem()
+	if err != nil {
 		return err
-	})
-}
+	}
 
-// This deselects all files, but (importantly) keeps global ignore patterns
+	return fld.whilePaused(func() error {
+		if _, err := ffs.Lstat(name); errors.Is(err, fs.ErrNotExist) {
+			if _, err := ffs.Lstat(fc.MarkerName); err == nil {
+				slog.Info("moving folder marker", "folderID", fld.FolderID, "from", fc.MarkerName, "to", name)
+				if err := ffs.Rename(fc.MarkerName, name); err != nil {
+					return err
+				}
+			} else {
+				slog.Info("creating folder marker", "folderID", fld.FolderID, "name", name)
+				if err := ffs.Mkdir(name, 0o755); err != nil {
+					return err
+				}
+				_ = ffs.Hide(name)
+			}
+		} else if err != nil {
+			return err
+		}
+
+		return fld.changeFolderConfiguration(func(config *config.FolderConfiguration) {
+			config.MarkerName = name
+		})
+	})
+}
+
+var (
+	errNoClient             = errors.New("client not started up yet")
+	errFolderConfigNotFound = errors.New("folder config not founnd")
+)
+
+func (fld *Folder) whilePaused(block func() error) error {
+	pausedBefore := fld.IsPaused()
+	if !pausedBefore {
+		err := fld.SetPaused(true)
+		if err != nil {
+			return err
+		}
+		defer fld.SetPaused(pausedBefore)
+	}
+	return block()
+}
+
+func (fld *Folder) SetSelective(selective bool) error {
+	slog.Info("SetSelective", "folder", fld.FolderID, "selective", selective)
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return errNoClient
+	}
+	if selective && fld.isSendOnly() {
+		return errors.New("send-only folders cannot be selective")
+	}
+	fld.cachedIgnore.matcher = nil // Purge our cache
+
+	return fld.whilePaused(func() error {
+		_, err := fld.changeSelection(func(selection *selection) error {
+			return selection.setSelective(selective)
+		})
+		return err
+	})
+}
+
+// This deselects all files, but (importantly) keeps global ignore patterns
 func (fld *Folder) ClearSelection() error {
 	_, err := fld.changeSelection(func(selection *selection) error {
 		if !selection.isSelectiveIgnore() {
@@ -554,6 +1064,20 @@ func (fld *Folder) ClearSelection() error {
 	return fld.CleanSelection()
 }
 
+// Rewrites the ignore lines of a selective folder that were made invalid (e.g. by manual edits) into a valid selective
+// form, keeping selected paths and global ignores.
+func (fld *Folder) RepairSelection() error {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return errNoClient
+	}
+
+	_, err := fld.changeSelection(func(selection *selection) error {
+		selection.repair()
+		return nil
+	})
+	return err
+}
+
 func (fld *Folder) SelectedPaths(onlyExisting bool) (*ListOfStrings, error) {
 	fc := fld.folderConfiguration()
 	if fc == nil {
@@ -586,12 +1110,31 @@ func (fld *Folder) SelectedPaths(onlyExisting bool) (*ListOfStrings, error) {
 }
 
 const (
-	FolderTypeSendReceive      = "sendrecieve"
+	FolderTypeSendReceive      = "sendreceive"
 	FolderTypeReceiveOnly      = "receiveonly"
 	FolderTypeSendOnly         = "sendonly"
 	FolderTypeReceiveEncrypted = "receiveencrypted"
+
+	// Misspelled value that FolderTypeSendReceive used to have, still accepted by SetFolderType
+	legacyFolderTypeSendReceive = "sendrecieve"
 )
 
+// Returns the Syncthing folder type for one of the FolderType* constants, and false for unknown types
+func parseFolderType(folderType string) (config.FolderType, bool) {
+	switch folderType {
+	case FolderTypeReceiveOnly:
+		return config.FolderTypeReceiveOnly, true
+	case FolderTypeSendReceive, legacyFolderTypeSendReceive:
+		return config.FolderTypeSendReceive, true
+	case FolderTypeSendOnly:
+		return config.FolderTypeSendOnly, true
+	case FolderTypeReceiveEncrypted:
+		return config.FolderTypeReceiveEncrypted, true
+	default:
+		return 0, false
+	}
+}
+
 func (fld *Folder) FolderType() string {
 	fc := fld.folderConfiguration()
 	if fc == nil {
@@ -612,6 +1155,16 @@ func (fld *Folder) FolderType() string {
 	}
 }
 
+// Returns true when this folder stores data as received from peers, encrypted with a password that this device does not
+// know (receive-encrypted). File names and contents in such folders are encrypted and cannot be displayed.
+func (fld *Folder) IsEncryptedAtRest() bool {
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return false
+	}
+	return fc.Type == config.FolderTypeReceiveEncrypted
+}
+
 // Returns true when this folder is 'external', i.e. some other app's folder
 func (fld *Folder) IsExternal() (bool, error) {
 	fc := fld.folderConfiguration()
@@ -647,22 +1200,18 @@ func (fld *Folder) FilesystemType() string {
 
 func (fld *Folder) SetFolderType(folderType string) error {
 	return fld.changeFolderConfiguration(func(fc *config.FolderConfiguration) {
-		switch folderType {
-		case FolderTypeReceiveOnly:
-			fc.Type = config.FolderTypeReceiveOnly
-		case FolderTypeSendReceive:
-			fc.Type = config.FolderTypeSendReceive
-		case FolderTypeSendOnly:
-			fc.Type = config.FolderTypeSendOnly
-		case FolderTypeReceiveEncrypted:
-			fc.Type = config.FolderTypeReceiveEncrypted
-		default:
-			// Don't change
-			return
+		if ft, ok := parseFolderType(folderType); ok {
+			fc.Type = ft
 		}
+		// Otherwise, don't change
 	})
 }
 
+func (fld *Folder) isSendOnly() bool {
+	fc := fld.folderConfiguration()
+	return fc != nil && fc.Type == config.FolderTypeSendOnly
+}
+
 func (fld *Folder) IsSelective() bool {
 	if fld.client.app == nil || fld.client.app.Internals == nil {
 		return false
@@ -758,8 +1307,9 @@ func (fld *Folder) extraneousFiles(stopAtOne bool) (*ListOfStrings, error) {
 
 	selection := newSelection(ignores.Lines())
 
-	// Can't have extraneous files when you are not a selective ignore folder
-	if !selection.isSelectiveIgnore() {
+	// Can't have extraneous files when you are not a selective ignore folder. Send-only folders never receive files, so
+	// all local files are there intentionally.
+	if !selection.isSelectiveIgnore() || cfg.Type == config.FolderTypeSendOnly {
 		return &ListOfStrings{}, nil
 	}
 
@@ -834,36 +1384,65 @@ func (fld *Folder) CleanSelection() error {
 		// Make sure the initial scan has finished (ScanFolders is blocking)
 		fld.client.app.Internals.ScanFolderSubdirs(fld.FolderID, []string{""})
 
-		cfg := fld.folderConfiguration()
-		if cfg == nil {
-			return errors.New("folder does not exist")
-		}
+		return fld.walkIgnoredLocalPaths(func(ffs fs.Filesystem, path string, info fs.FileInfo) error {
+			return ffs.RemoveAll(path)
+		})
+	})
+}
 
-		ignores, err := fld.loadIgnores()
-		if err != nil {
-			return err
-		}
+/*
+* Returns the paths of the local files and directories that CleanSelection would remove, without removing anything.
+Directories are listed once; the files inside them are not listed separately.
+*/
+func (fld *Folder) CleanSelectionPreview() (*ListOfStrings, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, errNoClient
+	}
 
-		fc := fld.folderConfiguration()
-		if fc == nil {
-			return errors.New("folder does not exist")
+	paths := make([]string, 0)
+	err := fld.walkIgnoredLocalPaths(func(ffs fs.Filesystem, path string, info fs.FileInfo) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return List(paths), nil
+}
+
+// Calls block for each local file or directory that is ignored (deselected). Directories are not descended into after
+// block has been called for them.
+func (fld *Folder) walkIgnoredLocalPaths(block func(ffs fs.Filesystem, path string, info fs.FileInfo) error) error {
+	cfg := fld.folderConfiguration()
+	if cfg == nil {
+		return errors.New("folder does not exist")
+	}
+
+	ignores, err := fld.loadIgnores()
+	if err != nil {
+		return err
+	}
+
+	ffs := cfg.Filesystem()
+	return ffs.Walk("", func(path string, info fs.FileInfo, err error) error {
+		if strings.HasPrefix(path, cfg.MarkerName) {
+			return nil
+		}
+		if path == ignoreFileName {
+			return nil
 		}
-		ffs := fc.Filesystem()
-		return ffs.Walk("", func(path string, info fs.FileInfo, err error) error {
-			if strings.HasPrefix(path, cfg.MarkerName) {
-				return nil
-			}
-			if path == ignoreFileName {
-				return nil
-			}
 
-			// Check ignore status
-			result := ignores.Match(path)
-			if result.IsIgnored() {
-				return ffs.RemoveAll(path)
+		// Check ignore status
+		result := ignores.Match(path)
+		if result.IsIgnored() {
+			if err := block(ffs, path, info); err != nil {
+				return err
 			}
-			return nil
-		})
+			if info != nil && info.IsDir() {
+				return fs.SkipDir
+			}
+		}
+		return nil
 	})
 }
 
@@ -1060,22 +1639,22 @@ func (fld *Folder) deleteLocalFileAndRedundantChildren(path string) error {
 	return nil
 }
 
-func (fld *Folder) reloadIgnores() error {
-	if !fld.IsPaused() {
-		err := fld.SetPaused(true)
-		if err != nil {
-			return err
-		}
-		fld.SetPaused(false)
+/*
+* Reloads the ignore patterns after the ignore file was changed outside of the app. The folder runner reloads its matcher
+when scanning and schedules a pull when the patterns changed, so a targeted scan of just the ignore file suffices (and
+does not interrupt the folder). Note that Internals.Ignores cannot be used for this: it reloads the matcher shared with the
+folder runner, which then would not notice the change. Paused folders load their ignores when they are resumed.
+*/
+func (fld *Folder) ReloadIgnores() error {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return ErrStillLoading
+	}
 
-		// Force a (minimal) scan. The current implementation also reloads the ignore file here (regardless of the path that is scanned)
-		// Note, this could potentially take a while
-		err = fld.client.app.Internals.ScanFolderSubdirs(fld.FolderID, []string{ignoreFileName})
-		if err != nil {
-			return err
-		}
+	fld.cachedIgnore.matcher = nil // Purge our cache
+	if fld.IsPaused() {
+		return nil
 	}
-	return nil
+	return fld.client.app.Internals.ScanFolderSubdirs(fld.FolderID, []string{ignoreFileName})
 }
 
 func (fld *Folder) SetExplicitlySelectedJSON(js []byte) error {
@@ -1086,6 +1665,91 @@ func (fld *Folder) SetExplicitlySelectedJSON(js []byte) error {
 	return fld.setExplicitlySelected(paths)
 }
 
+const selectionExportVersion = 1
+
+// Portable representation of the selection in a selective folder
+type selectionExport struct {
+	Version       int      `json:"version"`
+	FolderID      string   `json:"folderID"`
+	SelectedPaths []string `json:"selectedPaths"`
+}
+
+// Returns the explicitly selected paths of a selective folder as JSON, which can be applied to the same folder on another
+// device using ImportSelectionJSON
+func (fld *Folder) ExportSelectionJSON() ([]byte, error) {
+	if !fld.IsSelective() {
+		return nil, errors.New("folder is not a selective folder")
+	}
+
+	paths, err := fld.SelectedPaths(false)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(selectionExport{
+		Version:       selectionExportVersion,
+		FolderID:      fld.FolderID,
+		SelectedPaths: paths.data,
+	})
+}
+
+// Determines which paths to (de)select to import a selection. Imported paths that do not exist are skipped. When
+// replace is set, currently selected paths that are not in the imported selection are deselected.
+func selectionImportChanges(current []string, imported []string, exists func(path string) bool, replace bool) map[string]bool {
+	imported = Map(imported, func(path string) string {
+		return strings.Trim(path, "/")
+	})
+
+	changes := make(map[string]bool)
+	for _, path := range imported {
+		if path != "" && !slices.Contains(current, path) && exists(path) {
+			changes[path] = true
+		}
+	}
+
+	if replace {
+		for _, path := range current {
+			if !slices.Contains(imported, path) {
+				changes[path] = false
+			}
+		}
+	}
+	return changes
+}
+
+/*
+* Applies a selection exported with ExportSelectionJSON. Paths that do not exist in the global index are skipped. When
+`deleteDeselected` is set, paths that are currently selected but not in the imported selection are deselected (and their
+local copies removed), so that the selection matches the imported one exactly. Otherwise the imported paths are added to
+the current selection.
+*/
+func (fld *Folder) ImportSelectionJSON(js []byte, deleteDeselected bool) error {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return ErrStillLoading
+	}
+	if !fld.IsSelective() {
+		return errors.New("folder is not a selective folder")
+	}
+
+	var export selectionExport
+	if err := json.Unmarshal(js, &export); err != nil {
+		return err
+	}
+	if export.Version != selectionExportVersion {
+		return fmt.Errorf("unsupported selection export version %d", export.Version)
+	}
+
+	current, err := fld.SelectedPaths(false)
+	if err != nil {
+		return err
+	}
+
+	exists := func(path string) bool {
+		info, ok, err := fld.client.app.Internals.GlobalFileInfo(fld.FolderID, path)
+		return err == nil && ok && !info.IsDeleted()
+	}
+	return fld.setExplicitlySelected(selectionImportChanges(current.data, export.SelectedPaths, exists, deleteDeselected))
+}
+
 func (fld *Folder) IgnoreLines() (*ListOfStrings, error) {
 	// Load ignores from file
 	ignores, err := fld.loadIgnores()
@@ -1117,6 +1781,94 @@ func (fld *Folder) SetIgnoreLines(lines *ListOfStrings) error {
 	return nil
 }
 
+// Compiles the given ignore lines in memory (without writing them to disk) and returns an error describing the first
+// line that could not be parsed, if any.
+func (fld *Folder) ValidateIgnoreLines(lines *ListOfStrings) error {
+	_, err := fld.compileIgnoreLines(lines.data)
+	return err
+}
+
+// Returns whether the given path would be ignored when the given (proposed, unsaved) ignore lines were in effect
+func (fld *Folder) MatchesIgnore(lines *ListOfStrings, path string) (bool, error) {
+	matcher, err := fld.compileIgnoreLines(lines.data)
+	if err != nil {
+		return false, err
+	}
+	return matcher.Match(strings.TrimPrefix(path, "/")).IsIgnored(), nil
+}
+
+func (fld *Folder) compileIgnoreLines(lines []string) (*ignore.Matcher, error) {
+	cfg := fld.folderConfiguration()
+	if cfg == nil {
+		return nil, errors.New("folder does not exist")
+	}
+
+	// Included files are resolved relative to the folder root
+	matcher := ignore.New(cfg.Filesystem(), ignore.WithCache(false))
+	if err := matcher.Parse(strings.NewReader(strings.Join(lines, "\n")), ignoreFileName); err != nil {
+		// Find the offending line by parsing increasingly longer prefixes; errors only depend on preceding lines
+		for lineNumber := 1; lineNumber <= len(lines); lineNumber++ {
+			probe := ignore.New(cfg.Filesystem(), ignore.WithCache(false))
+			if probeErr := probe.Parse(strings.NewReader(strings.Join(lines[:lineNumber], "\n")), ignoreFileName); probeErr != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, probeErr)
+			}
+		}
+		return nil, err
+	}
+	return matcher, nil
+}
+
+// Translates ignore patterns in the given format ("gitignore" or "stignore") and adds them to the ignore patterns of
+// the folder. Existing patterns take precedence over imported ones. In selective folders, only file name patterns
+// (e.g. "*.tmp") can be imported; these are added as global ignore patterns.
+func (fld *Folder) ImportIgnorePatterns(content string, format string) error {
+	var patterns []string
+	switch format {
+	case "gitignore":
+		patterns = ignorePatternsFromGitignore(content)
+	case "stignore":
+		patterns = make([]string, 0)
+		for _, line := range strings.Split(content, "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if !isCommentPattern(line) {
+				patterns = append(patterns, line)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported ignore file format: %s", format)
+	}
+
+	if fld.IsSelective() {
+		_, err := fld.changeSelection(func(sel *selection) error {
+			globalPatterns := sel.globalIgnorePatterns()
+			for _, pattern := range patterns {
+				globalPattern := "(?d)" + pattern
+				if strings.HasPrefix(pattern, "!") || !isGlobalIgnorePattern(globalPattern) {
+					return fmt.Errorf("pattern cannot be imported in a selective folder: '%s'", pattern)
+				}
+				if !slices.Contains(globalPatterns, globalPattern) {
+					globalPatterns = append(globalPatterns, globalPattern)
+				}
+			}
+			return sel.setGlobalIgnorePatterns(globalPatterns)
+		})
+		return err
+	}
+
+	ignores, err := fld.loadIgnores()
+	if err != nil {
+		return err
+	}
+
+	lines := slices.Clone(ignores.Lines())
+	for _, pattern := range patterns {
+		if !slices.Contains(lines, pattern) {
+			lines = append(lines, pattern)
+		}
+	}
+	return fld.SetIgnoreLines(List(lines))
+}
+
 // Returns the list of global ignore patterns in a selective folder
 func (fld *Folder) GetSelectiveGlobalIgnorePatterns() (*ListOfStrings, error) {
 	// Load ignores from file
@@ -1256,44 +2008,635 @@ func (fld *Folder) SetLocalPathsExplicitlySelected(paths *ListOfStrings) error {
 	return fld.setExplicitlySelected(pathsMap)
 }
 
-func (fld *Folder) SetLocalFileExplicitlySelected(path string, toggle bool) error {
-	pathsMap := map[string]bool{}
-	pathsMap[path] = toggle
-	return fld.setExplicitlySelected(pathsMap)
-}
-
-func (fld *Folder) Statistics() (*FolderStats, error) {
+// Returns the number of bytes that would need to be downloaded to make the files at the specified paths available
+// locally. Directories are counted recursively. Files that are already locally present are not counted.
+func (fld *Folder) SelectionDownloadCost(paths *ListOfStrings) (int64, error) {
 	if fld.client.app == nil || fld.client.app.Internals == nil {
-		return nil, ErrStillLoading
+		return 0, ErrStillLoading
 	}
 
-	internals := fld.client.app.Internals
-	globalSize, err := internals.GlobalSize(fld.FolderID)
+	ffs, err := fld.filesystem()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	localSize, err := internals.LocalSize(fld.FolderID)
-	if err != nil {
-		return nil, err
+	isLocal := func(path string) bool {
+		_, err := ffs.Lstat(osutil.NativeFilename(path))
+		return err == nil
 	}
 
-	needSize, err := internals.NeedSize(fld.FolderID, protocol.LocalDeviceID)
-	if err != nil {
-		return nil, err
-	}
+	var cost int64 = 0
+	for _, path := range paths.data {
+		entry, err := fld.GetFileInformation(path)
+		if err != nil {
+			return 0, err
+		}
+		if entry == nil || entry.IsDeleted() || entry.IsSymlink() {
+			continue
+		}
 
-	return &FolderStats{
-		Global:    newFolderCounts(globalSize),
-		Local:     newFolderCounts(localSize),
-		LocalNeed: newFolderCounts(needSize),
-	}, nil
-}
+		if !entry.IsDirectory() {
+			if !isLocal(entry.Path()) {
+				cost += entry.Size()
+			}
+			continue
+		}
 
-type Completion struct {
-	CompletionPct float64
-	GlobalBytes   int64
-	NeedBytes     int64
+		leaves, err := fld.listEntries(entry.Path()+"/", false, true)
+		if err != nil {
+			return 0, err
+		}
+
+		err = walkEntries(entry.Path(), leaves, func(leafPrefix string, leaf *model.TreeEntry) (bool, error) {
+			if leaf.Type == protocol.FileInfoTypeFile.String() && !isLocal(leafPrefix+"/"+leaf.Name) {
+				cost += leaf.Size
+			}
+			return true, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return cost, nil
+}
+
+// Downloads all files in the subdirectory at prefix (recursively) into the folder, skipping files that are already
+// present locally. In selective folders the downloaded files are selected afterwards. When a file fails to download,
+// OnError is called with its path and the cause, and the remaining files are still downloaded. OnFinished is only called
+// when all files were downloaded successfully. Cancellation is checked between (and during) file downloads.
+func (fld *Folder) DownloadSubdirectory(prefix string, delegate DownloadDelegate) {
+	go func() {
+		if fld.client.app == nil || fld.client.app.Internals == nil {
+			delegate.OnError(ErrStillLoading.Error())
+			return
+		}
+
+		localFolderPath, err := fld.LocalNativePath()
+		if err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+
+		fc := fld.folderConfiguration()
+		if fc == nil {
+			delegate.OnError("folder does not exist")
+			return
+		}
+
+		prefix = strings.Trim(prefix, "/")
+		listPrefix := prefix
+		if listPrefix != "" {
+			listPrefix += "/"
+		}
+		leaves, err := fld.listEntries(listPrefix, false, true)
+		if err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+
+		// Find the files we do not have locally yet
+		paths := make([]string, 0)
+		var totalBytes int64 = 0
+		err = walkEntries(prefix, leaves, func(leafPrefix string, leaf *model.TreeEntry) (bool, error) {
+			if leaf.Type != protocol.FileInfoTypeFile.String() {
+				return true, nil
+			}
+
+			leafPath := strings.TrimPrefix(leafPrefix+"/"+leaf.Name, "/")
+			if _, err := os.Lstat(path.Join(localFolderPath, osutil.NativeFilename(leafPath))); err == nil {
+				return true, nil
+			}
+
+			paths = append(paths, leafPath)
+			totalBytes += leaf.Size
+			return true, nil
+		})
+		if err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+
+		slog.Info("download subdirectory", "prefix", prefix, "files", len(paths), "bytes", totalBytes)
+		delegate.OnProgress(0.0)
+
+		var doneBytes int64 = 0
+		anyFailed := false
+		downloaded := make(map[string]bool, 0)
+		for _, filePath := range paths {
+			if delegate.IsCancelled() {
+				return
+			}
+
+			entry, err := fld.GetFileInformation(filePath)
+			if err != nil || entry == nil {
+				anyFailed = true
+				delegate.OnError(fmt.Sprintf("%s: file not found", filePath))
+				continue
+			}
+
+			failed := false
+			subDelegate := &subDownloadDelegate{
+				parent: delegate,
+				errorCallback: func(err string) {
+					if !failed {
+						failed = true
+						delegate.OnError(fmt.Sprintf("%s: %s", filePath, err))
+					}
+				},
+				progressCallback: func(fraction float64) {
+					if totalBytes > 0 {
+						delegate.OnProgress((float64(doneBytes) + fraction*float64(entry.Size())) / float64(totalBytes))
+					}
+				},
+			}
+
+			if err := fld.downloadIntoFolder(fc, entry, subDelegate, false); err != nil {
+				subDelegate.OnError(err.Error())
+			}
+			doneBytes += entry.Size()
+
+			if failed {
+				anyFailed = true
+				continue
+			}
+			downloaded[filePath] = true
+		}
+
+		if delegate.IsCancelled() {
+			return
+		}
+
+		// Select the files that we now have, so Syncthing will keep them up to date
+		if len(downloaded) > 0 && fld.IsSelective() {
+			if err := fld.setExplicitlySelected(downloaded); err != nil {
+				delegate.OnError(err.Error())
+				return
+			}
+		}
+
+		if !anyFailed {
+			delegate.OnFinished(path.Join(localFolderPath, osutil.NativeFilename(prefix)))
+		}
+	}()
+}
+
+// Downloads a file to a temporary file next to its final location in the folder, then moves it into place. The file is
+// written through the folder's filesystem. When verify is set, the downloaded data is checked against the block hashes
+// before the file is moved into place.
+func (fld *Folder) downloadIntoFolder(fc *config.FolderConfiguration, entry *Entry, delegate *subDownloadDelegate, verify bool) error {
+	ffs := fc.Filesystem()
+	targetName := osutil.NativeFilename(entry.Path())
+	if err := ffs.MkdirAll(filepath.Dir(targetName), 0o755); err != nil {
+		return err
+	}
+
+	// Syncthing will not pick up files named like this while we are writing them
+	tempName := filepath.Join(filepath.Dir(targetName), ".syncthing."+filepath.Base(targetName)+".tmp")
+	failed := false
+	errorCallback := delegate.errorCallback
+	delegate.errorCallback = func(err string) {
+		failed = true
+		errorCallback(err)
+	}
+	entry.downloadFileInto(ffs, tempName, delegate, false, verify)
+	if failed || delegate.IsCancelled() {
+		ffs.Remove(tempName)
+		return nil
+	}
+
+	// Match the global metadata, so that a scan does not consider the file to be locally changed
+	if !fc.IgnorePerms {
+		if err := ffs.Chmod(tempName, fs.FileMode(entry.info.Permissions&0o777)); err != nil {
+			ffs.Remove(tempName)
+			return err
+		}
+	}
+	modTime := entry.info.ModTime()
+	if err := ffs.Chtimes(tempName, modTime, modTime); err != nil {
+		ffs.Remove(tempName)
+		return err
+	}
+	return ffs.Rename(tempName, targetName)
+}
+
+// Returns the total size of all files in the global index that are selected (i.e. not ignored), regardless of
+// whether they have been downloaded yet
+func (fld *Folder) SelectedSizeBytes() (int64, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return 0, ErrStillLoading
+	}
+
+	matcher, err := fld.loadIgnores()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64 = 0
+	for f, err := range zipError(fld.client.app.Internals.AllGlobalFiles(fld.FolderID)) {
+		if err != nil {
+			return 0, err
+		}
+
+		if f.Deleted || f.Type != protocol.FileInfoTypeFile {
+			continue
+		}
+
+		if !matcher.Match(f.Name).IsIgnored() {
+			total += f.Size
+		}
+	}
+	return total, nil
+}
+
+type LocalPresence struct {
+	SelectedFiles int
+	SelectedBytes int64
+	PresentFiles  int
+	PresentBytes  int64
+}
+
+// Returns the percentage (0-100) of selected files that is present locally. Returns 100 when no files are selected.
+func (lp *LocalPresence) Percent() float64 {
+	if lp.SelectedFiles == 0 {
+		return 100.0
+	}
+	return float64(lp.PresentFiles) / float64(lp.SelectedFiles) * 100.0
+}
+
+// Returns the percentage (0-100) of the size of selected files that is present locally. Returns 100 when no files are
+// selected.
+func (lp *LocalPresence) BytesPercent() float64 {
+	if lp.SelectedBytes == 0 {
+		return 100.0
+	}
+	return float64(lp.PresentBytes) / float64(lp.SelectedBytes) * 100.0
+}
+
+/*
+* Returns how many of the selected (i.e. not ignored) files in the global index are present locally, by number and by
+size. Unlike completion, this does not consider whether local files are up to date: an outdated local copy counts as
+present. In non-selective folders all files are selected.
+*/
+func (fld *Folder) LocalPresence() (*LocalPresence, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	ffs, err := fld.filesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := fld.loadIgnores()
+	if err != nil {
+		return nil, err
+	}
+
+	presence := &LocalPresence{}
+	for f, err := range zipError(fld.client.app.Internals.AllGlobalFiles(fld.FolderID)) {
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Deleted || f.Type != protocol.FileInfoTypeFile || matcher.Match(f.Name).IsIgnored() {
+			continue
+		}
+
+		presence.SelectedFiles += 1
+		presence.SelectedBytes += f.Size
+		if stat, err := ffs.Lstat(osutil.NativeFilename(f.Name)); err == nil && stat.IsRegular() {
+			presence.PresentFiles += 1
+			presence.PresentBytes += f.Size
+		}
+	}
+	return presence, nil
+}
+
+// Returns the percentage (0-100) of selected files that is present locally (see LocalPresence)
+func (fld *Folder) LocalPresencePercent() (float64, error) {
+	presence, err := fld.LocalPresence()
+	if err != nil {
+		return 0, err
+	}
+	return presence.Percent(), nil
+}
+
+func (fld *Folder) SetLocalFileExplicitlySelected(path string, toggle bool) error {
+	pathsMap := map[string]bool{}
+	pathsMap[path] = toggle
+	return fld.setExplicitlySelected(pathsMap)
+}
+
+func (fld *Folder) Statistics() (*FolderStats, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	internals := fld.client.app.Internals
+	globalSize, err := internals.GlobalSize(fld.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	localSize, err := internals.LocalSize(fld.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	needSize, err := internals.NeedSize(fld.FolderID, protocol.LocalDeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FolderStats{
+		Global:    newFolderCounts(globalSize),
+		Local:     newFolderCounts(localSize),
+		LocalNeed: newFolderCounts(needSize),
+	}, nil
+}
+
+// Returns true when this device needs nothing for the folder: no files to download, and no deletions to carry out
+// locally. Send-only folders never need anything, as remote changes are not applied to them. Returns false while the
+// client is still loading or when the state cannot be determined.
+func (fld *Folder) IsFullySynced() bool {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return false
+	}
+	if fld.isSendOnly() {
+		return true
+	}
+
+	needSize, err := fld.client.app.Internals.NeedSize(fld.FolderID, protocol.LocalDeviceID)
+	if err != nil {
+		slog.Warn("could not determine need size", "folderID", fld.FolderID, "cause", err)
+		return false
+	}
+	return needSize.TotalItems() == 0 && needSize.Bytes == 0
+}
+
+// Returns the number of items that were deleted elsewhere and still need to be deleted locally, or zero when this cannot
+// be determined. Always zero for send-only folders.
+func (fld *Folder) PendingDeletes() int {
+	if fld.client.app == nil || fld.client.app.Internals == nil || fld.isSendOnly() {
+		return 0
+	}
+
+	needSize, err := fld.client.app.Internals.NeedSize(fld.FolderID, protocol.LocalDeviceID)
+	if err != nil {
+		slog.Warn("could not determine need size", "folderID", fld.FolderID, "cause", err)
+		return 0
+	}
+	return needSize.Deleted
+}
+
+// Returns the number of files and their total size in the global index, grouped by type (images, videos, etc.)
+func (fld *Folder) FileTypeBreakdown() (*FileTypeBreakdown, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	breakdown := newFileTypeBreakdown()
+	for f, err := range zipError(fld.client.app.Internals.AllGlobalFiles(fld.FolderID)) {
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Deleted || f.Type != protocol.FileInfoTypeFile {
+			continue
+		}
+
+		counts := breakdown.countsFor(FileTypeCategoryForExtension(filepath.Ext(f.Name)))
+		counts.Files += 1
+		counts.Bytes += f.Size
+	}
+
+	return breakdown, nil
+}
+
+type duplicateFile struct {
+	name       string
+	size       int64
+	blocksHash []byte
+}
+
+// Groups files with the same size and blocks hash. Only groups of more than one file are returned, largest files first.
+// Paths within a group are sorted.
+func groupDuplicateFiles(files []duplicateFile) [][]string {
+	type groupKey struct {
+		size       int64
+		blocksHash string
+	}
+
+	groups := make(map[groupKey][]string)
+	for _, f := range files {
+		if len(f.blocksHash) == 0 {
+			continue
+		}
+		key := groupKey{size: f.size, blocksHash: string(f.blocksHash)}
+		groups[key] = append(groups[key], f.name)
+	}
+
+	keys := Filter(KeysOf(groups), func(key groupKey) bool {
+		return len(groups[key]) > 1
+	})
+	for _, key := range keys {
+		slices.Sort(groups[key])
+	}
+	slices.SortFunc(keys, func(a groupKey, b groupKey) int {
+		if a.size != b.size {
+			return cmp.Compare(b.size, a.size)
+		}
+		return strings.Compare(groups[a][0], groups[b][0])
+	})
+
+	return Map(keys, func(key groupKey) []string {
+		return groups[key]
+	})
+}
+
+type DuplicateGroups struct {
+	groups [][]string
+}
+
+func (dg *DuplicateGroups) Count() int {
+	return len(dg.groups)
+}
+
+func (dg *DuplicateGroups) ItemAt(index int) *ListOfStrings {
+	return List(dg.groups[index])
+}
+
+func (dg *DuplicateGroups) JSON() ([]byte, error) {
+	return json.Marshal(dg.groups)
+}
+
+// Returns groups of (non-empty) files in the global index that have identical contents, i.e. the same blocks hash.
+// Only candidates that share their size with another file are looked up in detail, which keeps this reasonably fast.
+func (fld *Folder) DuplicateGroups() (*DuplicateGroups, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	internals := fld.client.app.Internals
+	namesBySize := make(map[int64][]string)
+	for f, err := range zipError(internals.AllGlobalFiles(fld.FolderID)) {
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Deleted || f.Type != protocol.FileInfoTypeFile || f.Size == 0 {
+			continue
+		}
+		namesBySize[f.Size] = append(namesBySize[f.Size], f.Name)
+	}
+
+	candidates := make([]duplicateFile, 0)
+	for size, names := range namesBySize {
+		if len(names) < 2 {
+			continue
+		}
+
+		for _, name := range names {
+			info, ok, err := internals.GlobalFileInfo(fld.FolderID, name)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				candidates = append(candidates, duplicateFile{name: name, size: size, blocksHash: info.BlocksHash})
+			}
+		}
+	}
+
+	return &DuplicateGroups{groups: groupDuplicateFiles(candidates)}, nil
+}
+
+type SubtreeStatus struct {
+	InSync       int
+	NeedDownload int
+	NeedUpload   int
+	Conflicting  int
+	NotSelected  int
+}
+
+func isInSubtree(name string, prefix string) bool {
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+// Returns the number of files in the subtree at path (or the whole folder when path is empty) that are in sync, need
+// to be downloaded, need to be sent to a peer, are conflict copies, or are not selected (in selective folders).
+func (fld *Folder) SubtreeStatus(path string) (*SubtreeStatus, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	internals := fld.client.app.Internals
+	prefix := strings.Trim(path, "/")
+
+	// Files we need
+	needed := map[string]bool{}
+	for page := 1; ; page++ {
+		progress, queued, rest, err := internals.NeedFolderFiles(fld.FolderID, page, 512)
+		if err != nil {
+			return nil, err
+		}
+
+		batch := append(append(progress, queued...), rest...)
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, fi := range batch {
+			if isInSubtree(fi.FileName(), prefix) {
+				needed[fi.FileName()] = true
+			}
+		}
+	}
+
+	// Files peers need that we have (and thus can send)
+	devIDs, err := fld.sharedWith()
+	if err != nil {
+		return nil, err
+	}
+
+	neededByPeers := map[string]bool{}
+	for _, devID := range devIDs {
+		if devID == fld.client.deviceID() {
+			continue
+		}
+
+		for page := 1; ; page++ {
+			batch, err := internals.RemoteNeedFolderFiles(fld.FolderID, devID, page, 512)
+			if err != nil {
+				return nil, err
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, fi := range batch {
+				if isInSubtree(fi.FileName(), prefix) && !needed[fi.FileName()] {
+					neededByPeers[fi.FileName()] = true
+				}
+			}
+		}
+	}
+
+	matcher, err := fld.loadIgnores()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SubtreeStatus{}
+	for f, err := range zipError(internals.AllGlobalFiles(fld.FolderID)) {
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Deleted || f.Type != protocol.FileInfoTypeFile || !isInSubtree(f.Name, prefix) {
+			continue
+		}
+
+		switch {
+		case isConflictPath(f.Name):
+			status.Conflicting += 1
+		case needed[f.Name]:
+			status.NeedDownload += 1
+		case neededByPeers[f.Name]:
+			status.NeedUpload += 1
+		case matcher.Match(f.Name).IsIgnored():
+			status.NotSelected += 1
+		default:
+			status.InSync += 1
+		}
+	}
+
+	return status, nil
+}
+
+type ScanProgress struct {
+	Current        int64 // Bytes hashed so far
+	Total          int64 // Total bytes to hash
+	BytesPerSecond float64
+}
+
+// Returns the progress of the scan that is currently running for this folder, or nil when the folder is not scanning or
+// no progress has been reported yet. Progress is only reported when Client.ReportScanProgress was set before starting.
+func (fld *Folder) ScanProgress() *ScanProgress {
+	fld.client.mutex.Lock()
+	defer fld.client.mutex.Unlock()
+
+	if progress, ok := fld.client.scanProgress[fld.FolderID]; ok {
+		copied := *progress
+		return &copied
+	}
+	return nil
+}
+
+type Completion struct {
+	CompletionPct float64
+	GlobalBytes   int64
+	NeedBytes     int64
 	GlobalItems   int
 	NeedItems     int
 	NeedDeletes   int
@@ -1356,6 +2699,58 @@ func (fld *Folder) FilesNeeded() (*ListOfStrings, error) {
 	return List(files), nil
 }
 
+// Returns at most limit items starting at offset from a paged list, where fetch returns the items on a (1-based) page
+func pageFromOffset(offset int, limit int, fetch func(page int, perPage int) ([]protocol.FileInfo, error)) ([]protocol.FileInfo, error) {
+	items := make([]protocol.FileInfo, 0, limit)
+	page := offset/limit + 1
+	skip := offset % limit
+	for len(items) < limit {
+		batch, err := fetch(page, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range batch[min(skip, len(batch)):] {
+			if len(items) < limit {
+				items = append(items, item)
+			}
+		}
+		if len(batch) < limit {
+			break
+		}
+		skip = 0
+		page += 1
+	}
+	return items, nil
+}
+
+// Returns a page of the files this device still needs, in the order in which they will be pulled: files currently
+// being pulled first, then queued files, then the rest
+func (fld *Folder) NeededFiles(offset int, limit int) (*ListOfEntries, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+	if offset < 0 || limit <= 0 {
+		return nil, errors.New("invalid offset or limit")
+	}
+
+	infos, err := pageFromOffset(offset, limit, func(page int, perPage int) ([]protocol.FileInfo, error) {
+		progress, queued, rest, err := fld.client.app.Internals.NeedFolderFiles(fld.FolderID, page, perPage)
+		if err != nil {
+			return nil, err
+		}
+		return append(append(progress, queued...), rest...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := Map(infos, func(info protocol.FileInfo) *Entry {
+		return &Entry{Folder: fld, info: info}
+	})
+	return &ListOfEntries{data: entries}, nil
+}
+
 func (fld *Folder) FilesNeededBy(peer string) (*ListOfStrings, error) {
 	var devID protocol.DeviceID
 	var err error
@@ -1401,6 +2796,50 @@ func (fld *Folder) IsDiskSpaceSufficient() bool {
 	return fld.client.IsDiskSpaceSufficient()
 }
 
+type FolderDiskSpace struct {
+	Free  int64
+	Total int64
+}
+
+// Returns the free and total space in bytes on the volume the folder is stored on, which may differ from the volume
+// that holds the database (see GetFreeDiskSpaceMegaBytes)
+func (fld *Folder) AvailableDiskSpace() (*FolderDiskSpace, error) {
+	ffs, err := fld.filesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := ffs.Usage(".")
+	if err != nil {
+		return nil, err
+	}
+	return &FolderDiskSpace{
+		Free:  int64(usage.Free),
+		Total: int64(usage.Total),
+	}, nil
+}
+
+// Returns whether the specified number of bytes can be stored in the folder while keeping the minimum free disk space
+// configured for the folder. Returns false when the available space cannot be determined.
+func (fld *Folder) CanFit(bytes int64) bool {
+	fc := fld.folderConfiguration()
+	if fc == nil || bytes < 0 {
+		return false
+	}
+
+	ffs, err := fld.filesystem()
+	if err != nil {
+		return false
+	}
+
+	usage, err := ffs.Usage(".")
+	if err != nil || usage.Free < uint64(bytes) {
+		return false
+	}
+	usage.Free -= uint64(bytes)
+	return config.CheckFreeSpace(fc.MinDiskFree, usage) == nil
+}
+
 func (fld *Folder) IsBlockIndexingEnabled() bool {
 	fc := fld.folderConfiguration()
 	if fc == nil {
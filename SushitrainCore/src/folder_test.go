@@ -0,0 +1,169 @@
+package sushitrain
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestGroupDuplicateFiles(t *testing.T) {
+	files := []duplicateFile{
+		{name: "b/photo.jpg", size: 10, blocksHash: []byte("A")},
+		{name: "a/photo copy.jpg", size: 10, blocksHash: []byte("A")},
+		{name: "other.jpg", size: 10, blocksHash: []byte("B")},
+		{name: "big.mov", size: 100, blocksHash: []byte("C")},
+		{name: "big copy.mov", size: 100, blocksHash: []byte("C")},
+		{name: "unhashed.txt", size: 100},
+		{name: "unhashed copy.txt", size: 100},
+	}
+
+	groups := groupDuplicateFiles(files)
+	expected := [][]string{
+		{"big copy.mov", "big.mov"},
+		{"a/photo copy.jpg", "b/photo.jpg"},
+	}
+	if !slices.EqualFunc(groups, expected, slices.Equal) {
+		t.Errorf("unexpected groups: %v", groups)
+	}
+}
+
+func TestSelectionImportChanges(t *testing.T) {
+	current := []string{"keep.jpg", "old/file.txt"}
+	imported := []string{"/keep.jpg", "/new/photo.jpg", "missing.jpg"}
+	exists := func(path string) bool {
+		return path != "missing.jpg"
+	}
+
+	merged := selectionImportChanges(current, imported, exists, false)
+	if !maps.Equal(merged, map[string]bool{"new/photo.jpg": true}) {
+		t.Errorf("unexpected changes when merging: %v", merged)
+	}
+
+	replaced := selectionImportChanges(current, imported, exists, true)
+	if !maps.Equal(replaced, map[string]bool{"new/photo.jpg": true, "old/file.txt": false}) {
+		t.Errorf("unexpected changes when replacing: %v", replaced)
+	}
+}
+
+func TestPageFromOffset(t *testing.T) {
+	all := make([]protocol.FileInfo, 10)
+	for i := range all {
+		all[i] = protocol.FileInfo{Name: fmt.Sprintf("%d", i)}
+	}
+	fetch := func(page int, perPage int) ([]protocol.FileInfo, error) {
+		start := min((page-1)*perPage, len(all))
+		return all[start:min(start+perPage, len(all))], nil
+	}
+	names := func(infos []protocol.FileInfo) []string {
+		return Map(infos, func(info protocol.FileInfo) string { return info.Name })
+	}
+
+	cases := []struct {
+		offset   int
+		limit    int
+		expected []string
+	}{
+		{0, 3, []string{"0", "1", "2"}},
+		{3, 3, []string{"3", "4", "5"}},
+		{4, 3, []string{"4", "5", "6"}},
+		{8, 5, []string{"8", "9"}},
+		{12, 5, []string{}},
+	}
+	for _, c := range cases {
+		page, err := pageFromOffset(c.offset, c.limit, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(names(page), c.expected) {
+			t.Errorf("offset %d limit %d: expected %v, got %v", c.offset, c.limit, c.expected, names(page))
+		}
+	}
+}
+
+func TestParseFolderType(t *testing.T) {
+	for _, folderType := range []string{FolderTypeSendReceive, "sendreceive", "sendrecieve"} {
+		if ft, ok := parseFolderType(folderType); !ok || ft != config.FolderTypeSendReceive {
+			t.Errorf("%q should map to send-receive, got %v", folderType, ft)
+		}
+	}
+
+	if ft, ok := parseFolderType(FolderTypeSendOnly); !ok || ft != config.FolderTypeSendOnly {
+		t.Errorf("unexpected type for send-only: %v", ft)
+	}
+	if _, ok := parseFolderType("nonsense"); ok {
+		t.Error("unknown folder types should be rejected")
+	}
+}
+
+func TestFlattenListedEntries(t *testing.T) {
+	modTime := time.UnixMilli(1700000000123)
+	tree := []*model.TreeEntry{
+		{Name: "photos", Type: protocol.FileInfoTypeDirectory.String(), ModTime: modTime, Children: []*model.TreeEntry{
+			{Name: "a.jpg", Type: protocol.FileInfoTypeFile.String(), Size: 42, ModTime: modTime},
+		}},
+		{Name: "notes.txt", Type: protocol.FileInfoTypeFile.String(), Size: 7, ModTime: modTime},
+	}
+
+	present := func(path string) bool { return path == "root/photos/a.jpg" }
+
+	listed := flattenListedEntries(tree, true, "root/", "", present)
+	names := Map(listed, func(e listedEntry) string { return e.Name })
+	if !slices.Equal(names, []string{"photos", "photos/a.jpg", "notes.txt"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	if !listed[0].IsDir || listed[1].IsDir || listed[1].Size != 42 || listed[1].ModTimeMs != 1700000000123 {
+		t.Errorf("unexpected metadata: %+v", listed)
+	}
+	if listed[0].LocallyPresent || !listed[1].LocallyPresent {
+		t.Errorf("unexpected presence: %+v", listed)
+	}
+
+	if flat := flattenListedEntries(tree, false, "", "", present); len(flat) != 2 {
+		t.Errorf("non-recursive listing should only contain top-level items: %+v", flat)
+	}
+}
+
+func TestValidateEncryptionPassword(t *testing.T) {
+	for _, password := range []string{"", "short", "1234567"} {
+		if err := ValidateEncryptionPassword(password); !errors.Is(err, ErrEncryptionPasswordTooShort) {
+			t.Errorf("%q: expected too short, got %v", password, err)
+		}
+	}
+	for _, password := range []string{"aaaaaaaa", "12345678", "87654321", "abcdefgh", "Password", "qwerty123"} {
+		if err := ValidateEncryptionPassword(password); !IsWeakEncryptionPasswordError(err) {
+			t.Errorf("%q: expected weak, got %v", password, err)
+		}
+	}
+	for _, password := range []string{"correct horse battery", "x7#kP2!qzR", "ünïcödé-pässwörd"} {
+		if err := ValidateEncryptionPassword(password); err != nil {
+			t.Errorf("%q: expected valid, got %v", password, err)
+		}
+	}
+}
+
+func TestAddFolderDevices(t *testing.T) {
+	a := protocol.DeviceID{1}
+	b := protocol.DeviceID{2}
+	c := protocol.DeviceID{3}
+
+	fc := config.FolderConfiguration{
+		Devices: []config.FolderDeviceConfiguration{{DeviceID: a, EncryptionPassword: "old"}},
+	}
+	added := addFolderDevices(&fc, []protocol.DeviceID{a, b, c, b}, "secret")
+	if !slices.Equal(added, []protocol.DeviceID{b, c}) {
+		t.Errorf("unexpected added devices: %v", added)
+	}
+	if len(fc.Devices) != 3 {
+		t.Fatalf("expected three devices, got %d", len(fc.Devices))
+	}
+	if fc.Devices[0].EncryptionPassword != "old" || fc.Devices[1].EncryptionPassword != "secret" {
+		t.Errorf("existing devices should be left alone: %+v", fc.Devices)
+	}
+}
@@ -14,13 +14,20 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"fmt"
+	"html/template"
+	"io"
 	"math/big"
 	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
 	"golang.org/x/exp/slog"
 )
 
@@ -80,6 +87,33 @@ func (s *selfSignedCertificate) tlsCertificate() (*tls.Certificate, error) {
 	}, nil
 }
 
+func (s *selfSignedCertificate) tlsConfig() (*tls.Config, error) {
+	cert, err := s.tlsCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates:             []tls.Certificate{*cert},
+		MinVersion:               tls.VersionTLS12,
+		CurvePreferences:         []tls.CurveID{tls.CurveP384},
+		PreferServerCipherSuites: true,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		},
+	}, nil
+}
+
+func newCookieToken() string {
+	tokenLength := 64
+	b := make([]byte, tokenLength+2)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)[2 : tokenLength+2]
+}
+
 type FolderServer struct {
 	listener     net.Listener
 	client       *Client
@@ -87,6 +121,59 @@ type FolderServer struct {
 	subdirectory string
 	certificate  *selfSignedCertificate
 	cookieToken  string
+
+	// Requests for paths of which any component matches one of these patterns are answered with 404
+	denied pathDenyList
+
+	// When set, requests for directories without an index.html are answered with a listing of the directory contents
+	AutoIndex bool
+}
+
+// Paths denied by default: dotfiles and directories (e.g. .git, .stfolder, .DS_Store) and common system files
+var defaultFolderServerDeniedPatterns = []string{".*", "~syncthing~*", "Thumbs.db", "desktop.ini"}
+
+// Set of patterns (in filepath.Match syntax) that is matched against each component of a path, shared by the
+// folder and WebDAV servers
+type pathDenyList struct {
+	patterns []string
+	mutex    sync.Mutex
+}
+
+func newPathDenyList(patterns []string) pathDenyList {
+	return pathDenyList{patterns: slices.Clone(patterns)}
+}
+
+func (l *pathDenyList) set(patterns *ListOfStrings) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if patterns == nil {
+		l.patterns = nil
+		return nil
+	}
+
+	for _, pattern := range patterns.data {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+	l.patterns = slices.Clone(patterns.data)
+	return nil
+}
+
+func (l *pathDenyList) isDenied(path string) bool {
+	l.mutex.Lock()
+	patterns := l.patterns
+	l.mutex.Unlock()
+
+	for _, component := range strings.Split(path, "/") {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, component); matched {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func NewFolderServer(client *Client, folderID string, subdirectory string) *FolderServer {
@@ -96,10 +183,7 @@ func NewFolderServer(client *Client, folderID string, subdirectory string) *Fold
 		return nil
 	}
 
-	tokenLength := 64
-	b := make([]byte, tokenLength+2)
-	rand.Read(b)
-	cookieToken := fmt.Sprintf("%x", b)[2 : tokenLength+2]
+	cookieToken := newCookieToken()
 
 	return &FolderServer{
 		folderID:     folderID,
@@ -108,9 +192,16 @@ func NewFolderServer(client *Client, folderID string, subdirectory string) *Fold
 		client:       client,
 		certificate:  cert,
 		cookieToken:  cookieToken,
+		denied:       newPathDenyList(defaultFolderServerDeniedPatterns),
 	}
 }
 
+// Set the patterns (in filepath.Match syntax) that are matched against each path component of a request. Requests
+// for paths with a matching component are denied. Pass nil or an empty list to allow all paths.
+func (srv *FolderServer) SetDeniedPatterns(patterns *ListOfStrings) error {
+	return srv.denied.set(patterns)
+}
+
 func (srv *FolderServer) CookieValue() string {
 	return srv.cookieToken
 }
@@ -135,25 +226,12 @@ func (srv *FolderServer) Listen() error {
 	// Close existing listener
 	srv.Shutdown()
 
-	cert, err := srv.certificate.tlsCertificate()
+	config, err := srv.certificate.tlsConfig()
 	if err != nil {
 		slog.Error("could not obtain certificate", "cause", err)
 		return err
 	}
 
-	config := &tls.Config{
-		Certificates:             []tls.Certificate{*cert},
-		MinVersion:               tls.VersionTLS12,
-		CurvePreferences:         []tls.CurveID{tls.CurveP384},
-		PreferServerCipherSuites: true,
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		},
-	}
-
 	listener, err := tls.Listen("tcp", ":0", config)
 	if err != nil {
 		slog.Error("could not listen", "cause", err)
@@ -205,6 +283,12 @@ func (srv *FolderServer) handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if srv.denied.isDenied(path) {
+		slog.Info("folder server path is denied", "path", r.URL.Path)
+		w.WriteHeader(404)
+		return
+	}
+
 	stFolder := srv.client.FolderWithID(srv.folderID)
 	if stFolder == nil {
 		w.WriteHeader(404)
@@ -220,6 +304,10 @@ func (srv *FolderServer) handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if stEntry == nil || stEntry.IsDeleted() {
+		if srv.AutoIndex && strings.HasSuffix(r.URL.Path, "/") {
+			srv.serveDirectoryListing(w, r, stFolder, filepath.Dir(pathInFolder))
+			return
+		}
 		w.WriteHeader(404)
 		return
 	}
@@ -258,7 +346,79 @@ func (srv *FolderServer) handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Actually send the file
-	serveEntry(w, r, srv.folderID, stEntry, info, srv.client.app.Internals, srv.client.Measurements, nil)
+	serveEntry(w, r, srv.folderID, stEntry, info, srv.client.app.Internals, srv.client.Measurements, nil, nil)
+}
+
+var directoryListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{if .HasParent}}<li><a href="../">../</a></li>
+{{end}}{{range .Entries}}<li><a href="{{.Link}}">{{.Name}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+type directoryListingEntry struct {
+	Name string
+	Link string
+}
+
+// Responds with an HTML page listing the (non-denied) entries in the directory
+func (srv *FolderServer) serveDirectoryListing(w http.ResponseWriter, r *http.Request, stFolder *Folder, dirPath string) {
+	if dirPath == "." {
+		dirPath = ""
+	}
+
+	prefix := ""
+	if dirPath != "" {
+		dirEntry, err := stFolder.GetFileInformation(dirPath)
+		if err != nil || dirEntry == nil || dirEntry.IsDeleted() || !dirEntry.IsDirectory() {
+			w.WriteHeader(404)
+			return
+		}
+		prefix = dirPath + "/"
+	}
+
+	treeEntries, err := stFolder.listEntries(prefix, false, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	setNoCacheHeaders(w)
+	if err := writeDirectoryListing(w, r.URL.Path, treeEntries, srv.denied.isDenied); err != nil {
+		slog.Warn("could not write directory listing", "path", r.URL.Path, "cause", err)
+	}
+}
+
+func writeDirectoryListing(w io.Writer, requestPath string, treeEntries []*model.TreeEntry, isDenied func(name string) bool) error {
+	entries := make([]directoryListingEntry, 0, len(treeEntries))
+	for _, treeEntry := range treeEntries {
+		if isDenied(treeEntry.Name) {
+			continue
+		}
+
+		entry := directoryListingEntry{Name: treeEntry.Name, Link: url.PathEscape(treeEntry.Name)}
+		if treeEntry.Type == protocol.FileInfoTypeDirectory.String() {
+			entry.Name += "/"
+			entry.Link += "/"
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a directoryListingEntry, b directoryListingEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return directoryListingTemplate.Execute(w, struct {
+		Title     string
+		HasParent bool
+		Entries   []directoryListingEntry
+	}{requestPath, requestPath != "/", entries})
 }
 
 func (srv *FolderServer) port() int {
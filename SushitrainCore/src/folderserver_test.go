@@ -0,0 +1,46 @@
+package sushitrain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestWriteDirectoryListing(t *testing.T) {
+	srv := &FolderServer{denied: newPathDenyList(defaultFolderServerDeniedPatterns)}
+	entries := []*model.TreeEntry{
+		{Name: "b.txt", Type: protocol.FileInfoTypeFile.String()},
+		{Name: "a dir", Type: protocol.FileInfoTypeDirectory.String()},
+		{Name: ".hidden", Type: protocol.FileInfoTypeFile.String()},
+		{Name: "<script>.html", Type: protocol.FileInfoTypeFile.String()},
+	}
+
+	var buf bytes.Buffer
+	if err := writeDirectoryListing(&buf, "/sub/", entries, srv.denied.isDenied); err != nil {
+		t.Fatal(err)
+	}
+	listing := buf.String()
+
+	for _, expected := range []string{`href="../"`, `href="a%20dir/"`, `>a dir/<`, `href="b.txt"`, `&lt;script&gt;.html`} {
+		if !strings.Contains(listing, expected) {
+			t.Errorf("expected listing to contain %q:\n%s", expected, listing)
+		}
+	}
+	if strings.Contains(listing, ".hidden") {
+		t.Error("listing contains denied entry")
+	}
+	if strings.Index(listing, "a dir/") > strings.Index(listing, "b.txt") {
+		t.Error("listing is not sorted")
+	}
+
+	buf.Reset()
+	if err := writeDirectoryListing(&buf, "/", entries, srv.denied.isDenied); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `href="../"`) {
+		t.Error("root listing should not link to parent")
+	}
+}
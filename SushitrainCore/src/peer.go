@@ -6,6 +6,11 @@
 package sushitrain
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
@@ -15,6 +20,10 @@ import (
 type Peer struct {
 	client   *Client
 	deviceID protocol.DeviceID
+
+	// Name and addresses suggested by the peer itself (see ParseSharingPayload), used while the peer is not added
+	suggestedName      string
+	suggestedAddresses []string
 }
 
 func (peer *Peer) DeviceID() string {
@@ -49,6 +58,54 @@ func (peer *Peer) LastSeen() *Date {
 	return &Date{time: stats[peer.deviceID].LastSeen}
 }
 
+/*
+* Returns transfer statistics for the current connection to this peer. Only the connection duration is available:
+Syncthing keeps per-connection byte counters in its model (ConnectionStats), which it does not expose to embedders, so
+InBytes and OutBytes are always -1. Use Client.TotalTransferStatistics for byte counts across all peers.
+*/
+func (peer *Peer) TransferStatistics() (*TransferStats, error) {
+	if peer.client.app == nil || peer.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+	if !peer.IsConnected() {
+		return nil, errors.New("peer is not connected")
+	}
+
+	stats := &TransferStats{
+		InBytes:  -1,
+		OutBytes: -1,
+	}
+
+	clt := peer.client
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	if connectedSince, ok := clt.connectedDeviceSince[peer.deviceID.String()]; ok {
+		stats.DurationSeconds = int64(time.Since(connectedSince).Seconds())
+	}
+	return stats, nil
+}
+
+/*
+* Returns roughly how many files and deletions in the folder the peer has not caught up with yet, according to the index
+it last announced to us. Syncthing does not expose how far it has sent its own index to the peer, so this number includes
+changes that were announced but not yet pulled by the peer. A number that stays high while the peer is connected points
+at a problem on the side of the peer, whereas a number that goes down indicates the peer is still catching up.
+*/
+func (peer *Peer) PendingIndexUpdates(folderID string) (int, error) {
+	if peer.client.app == nil || peer.client.app.Internals == nil {
+		return 0, ErrStillLoading
+	}
+
+	completion, err := peer.client.app.Internals.Completion(peer.deviceID, folderID)
+	if err != nil {
+		return 0, err
+	}
+	if state := completion.RemoteState.String(); state != "valid" {
+		return 0, fmt.Errorf("folder state at peer is %s", state)
+	}
+	return completion.NeedItems + completion.NeedDeletes, nil
+}
+
 func (peer *Peer) deviceConfiguration() *config.DeviceConfiguration {
 	devs := peer.client.config.Devices()
 	dev, ok := devs[peer.deviceID]
@@ -62,7 +119,7 @@ func (peer *Peer) Name() string {
 	config := peer.deviceConfiguration()
 
 	if config == nil {
-		return ""
+		return peer.suggestedName
 	}
 
 	return config.Name
@@ -80,7 +137,11 @@ func (peer *Peer) SetName(name string) error {
 }
 
 func (peer *Peer) Addresses() *ListOfStrings {
-	return List(peer.deviceConfiguration().Addresses)
+	config := peer.deviceConfiguration()
+	if config == nil {
+		return List(peer.suggestedAddresses)
+	}
+	return List(config.Addresses)
 }
 
 func (peer *Peer) IsConnected() bool {
@@ -94,6 +155,59 @@ func (peer *Peer) IsConnected() bool {
 	return peer.client.app.Internals.IsConnectedTo(peer.deviceID)
 }
 
+// Returns the kind of connection to this peer, i.e. "tcp-lan", "tcp-wan", "quic-lan", "quic-wan" or "relay", or an empty
+// string when not connected
+func (peer *Peer) ConnectionType() string {
+	if !peer.IsConnected() {
+		return ""
+	}
+
+	clt := peer.client
+	clt.mutex.Lock()
+	connType := clt.connectedDeviceTypes[peer.deviceID.String()]
+	address := clt.connectedDeviceAddresses[peer.deviceID.String()]
+	clt.mutex.Unlock()
+
+	// Connection types are reported as e.g. "tcp-client" or "relay-server"
+	protocolName, _, _ := strings.Cut(connType, "-")
+	switch protocolName {
+	case "":
+		return ""
+	case "relay":
+		return "relay"
+	}
+
+	if isLANAddress(address) {
+		return protocolName + "-lan"
+	}
+	return protocolName + "-wan"
+}
+
+// Returns the remote address of the connection to this peer, or an empty string when not connected
+func (peer *Peer) ConnectionAddress() string {
+	if !peer.IsConnected() {
+		return ""
+	}
+	return peer.client.GetLastPeerAddress(peer.deviceID.String())
+}
+
+func (peer *Peer) IsConnectedViaRelay() bool {
+	return peer.ConnectionType() == "relay"
+}
+
+func isLANAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()
+}
+
 func (peer *Peer) SetPaused(paused bool) error {
 	return peer.client.changeConfiguration(func(cfg *config.Configuration) {
 		dc, ok := cfg.DeviceMap()[peer.deviceID]
@@ -156,6 +270,47 @@ func (peer *Peer) IntroducedBy() *Peer {
 	return peer.client.PeerWithID(did.String())
 }
 
+// Parses one of the compression modes "metadata", "always" or "never"
+func parseCompressionMode(mode string) (config.Compression, error) {
+	var compression config.Compression
+	switch mode {
+	case "metadata":
+		compression = config.CompressionMetadata
+	case "always":
+		compression = config.CompressionAlways
+	case "never":
+		compression = config.CompressionNever
+	default:
+		return compression, fmt.Errorf("invalid compression mode: %q", mode)
+	}
+	return compression, nil
+}
+
+// Returns the compression mode used for messages sent to this peer: "metadata" (only compress index data and other
+// metadata), "always" (also compress file data) or "never". Compressing file data helps on slow links, but costs CPU
+// (and thus battery) on fast ones.
+func (peer *Peer) CompressionMode() string {
+	dc := peer.deviceConfiguration()
+	if dc == nil {
+		return ""
+	}
+
+	mode, _ := dc.Compression.MarshalText()
+	return string(mode)
+}
+
+// Sets the compression mode for messages sent to this peer (see CompressionMode). Takes effect on the next connection.
+func (peer *Peer) SetCompressionMode(mode string) error {
+	compression, err := parseCompressionMode(mode)
+	if err != nil {
+		return err
+	}
+
+	return peer.changeDeviceConfiguration(func(dc *config.DeviceConfiguration) {
+		dc.Compression = compression
+	})
+}
+
 func (peer *Peer) changeDeviceConfiguration(block func(*config.DeviceConfiguration)) error {
 	return peer.client.changeConfiguration(func(cfg *config.Configuration) {
 		dc, ok := cfg.DeviceMap()[peer.deviceID]
@@ -199,6 +354,20 @@ func (peer *Peer) SharedFolderIDs() *ListOfStrings {
 	return List(sharedWith)
 }
 
+// Returns the IDs of our folders that are not (yet) shared with this peer
+func (peer *Peer) ShareableFolders() *ListOfStrings {
+	folders := peer.client.config.Folders()
+	shareable := make([]string, 0)
+
+	for fid, folder := range folders {
+		if !slices.Contains(folder.DeviceIDs(), peer.deviceID) {
+			shareable = append(shareable, fid)
+		}
+	}
+
+	return List(shareable)
+}
+
 func (peer *Peer) PendingFolderIDs() (*ListOfStrings, error) {
 	pfs, err := peer.client.app.Internals.PendingFolders(peer.deviceID)
 	if err != nil {
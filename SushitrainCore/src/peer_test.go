@@ -0,0 +1,33 @@
+package sushitrain
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestParseCompressionMode(t *testing.T) {
+	cases := map[string]config.Compression{
+		"metadata": config.CompressionMetadata,
+		"always":   config.CompressionAlways,
+		"never":    config.CompressionNever,
+	}
+	for mode, expected := range cases {
+		compression, err := parseCompressionMode(mode)
+		if err != nil || compression != expected {
+			t.Errorf("%s: got %v, %v", mode, compression, err)
+		}
+
+		// The mode should survive a round trip through the configuration
+		text, _ := compression.MarshalText()
+		if string(text) != mode {
+			t.Errorf("%s: marshals as %s", mode, text)
+		}
+	}
+
+	for _, mode := range []string{"", "true", "false", "Always", "sometimes"} {
+		if _, err := parseCompressionMode(mode); err == nil {
+			t.Errorf("%q should be rejected", mode)
+		}
+	}
+}
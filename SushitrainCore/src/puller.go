@@ -8,6 +8,7 @@ import (
 	"math"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -18,13 +19,33 @@ import (
 )
 
 // Global cache of downloaded blocks. Block hash -> block data
-// Blocks are between 128 KiB and 16 MiB size, this will use 1 GiB at most
-var blockCache, _ = lru.New[string, []byte](64)
+// Blocks are between 128 KiB and 16 MiB size, with the default size this will use 1 GiB at most
+const defaultBlockCacheSize = 64
+
+var blockCache, _ = lru.New[string, []byte](defaultBlockCacheSize)
+var blockCacheHits, blockCacheMisses atomic.Int64
+var currentBlockCacheSize = defaultBlockCacheSize
+var blockCacheSizeMutex sync.Mutex
+
+type BlockCacheStatistics struct {
+	Entries    int
+	MaxEntries int
+	Hits       int64
+	Misses     int64
+}
+
+// When no block could be obtained from any peer for this long, a download is considered stalled
+var downloadStallTimeout = time.Duration(10) * time.Second
+var downloadStallTimeoutMutex sync.Mutex
 
 type miniPuller struct {
 	measurements *Measurements
 	experiences  *experiences
 	internals    *syncthing.Internals
+
+	// Called with true when a block download stalls (no peer could provide it within downloadStallTimeout), and with
+	// false when the stalled block download ends (either successfully or not)
+	onStalled func(stalled bool)
 }
 
 func ClearBlockCache() {
@@ -32,6 +53,49 @@ func ClearBlockCache() {
 	blockCache.Purge()
 }
 
+// Set the maximum number of blocks kept in the block cache. Cached blocks are kept, except for the least recently used
+// ones when the cache shrinks.
+func SetBlockCacheSize(maxEntries int) error {
+	if maxEntries < 1 {
+		return errors.New("block cache size must be at least one")
+	}
+	blockCacheSizeMutex.Lock()
+	defer blockCacheSizeMutex.Unlock()
+	evicted := blockCache.Resize(maxEntries)
+	currentBlockCacheSize = maxEntries
+	slog.Info("Resized blocks cache", "maxEntries", maxEntries, "evicted", evicted)
+	return nil
+}
+
+// Returns the number of cached blocks and how often blocks were (not) found in the cache since the app started
+func BlockCacheStats() *BlockCacheStatistics {
+	return &BlockCacheStatistics{
+		Entries:    blockCache.Len(),
+		MaxEntries: blockCacheSize(),
+		Hits:       blockCacheHits.Load(),
+		Misses:     blockCacheMisses.Load(),
+	}
+}
+
+func blockCacheSize() int {
+	blockCacheSizeMutex.Lock()
+	defer blockCacheSizeMutex.Unlock()
+	return currentBlockCacheSize
+}
+
+// Set the time after which a download that is not receiving any data is considered stalled. Set to zero to disable.
+func SetDownloadStallTimeoutSeconds(seconds int) {
+	downloadStallTimeoutMutex.Lock()
+	defer downloadStallTimeoutMutex.Unlock()
+	downloadStallTimeout = time.Duration(max(0, seconds)) * time.Second
+}
+
+func currentDownloadStallTimeout() time.Duration {
+	downloadStallTimeoutMutex.Lock()
+	defer downloadStallTimeoutMutex.Unlock()
+	return downloadStallTimeout
+}
+
 // Download a range. Will retry until cancelled, and fail if there is no way a peer will come online to provide us the range
 func (mp *miniPuller) downloadRange(ctx context.Context, m *syncthing.Internals, folderID string, file protocol.FileInfo, dest []byte, offset int64) (n int64, e error) {
 	blockSize := int64(file.BlockSize())
@@ -114,8 +178,10 @@ func (mp *miniPuller) downloadBlock(ctx context.Context, folderID string, blockI
 	// Do we have this file in the local cache?
 	if cached, ok := blockCache.Get(blockHashString); ok {
 		slog.Debug("cache hit for block", "hash", blockHashString)
+		blockCacheHits.Add(1)
 		return cached, nil
 	}
+	blockCacheMisses.Add(1)
 
 	availables, err := mp.internals.BlockAvailability(folderID, file, block)
 	if err != nil {
@@ -126,25 +192,16 @@ func (mp *miniPuller) downloadBlock(ctx context.Context, folderID string, blockI
 	}
 
 	slog.Debug("download block", "index", blockIndex, "availablePeers", len(availables))
-
-	// Sort availables by latency
-	slices.SortFunc(availables, func(a model.Availability, b model.Availability) int {
-		latencyA := mp.measurements.LatencyFor(a.ID.String())
-		latencyB := mp.measurements.LatencyFor(b.ID.String())
-		if math.IsNaN(latencyA) && math.IsNaN(latencyB) {
-			return 0
-		} else if math.IsNaN(latencyA) {
-			return 1 // a > b
-		} else if math.IsNaN(latencyB) {
-			return -1 // b > a
-		} else if latencyA > latencyB {
-			return 1
-		} else if latencyB > latencyA {
-			return -1
-		} else {
-			return 0
+	mp.sortByLatency(availables)
+
+	// When we have been waiting for too long, let the caller know that the download has stalled
+	startTime := time.Now()
+	stalled := false
+	defer func() {
+		if stalled && mp.onStalled != nil {
+			mp.onStalled(false)
 		}
-	})
+	}()
 
 	// Attempt to download the block from an available and 'known good' peers first
 	var attempt = 0
@@ -152,6 +209,16 @@ func (mp *miniPuller) downloadBlock(ctx context.Context, folderID string, blockI
 		attempt += 1
 		slog.Debug("downloadBlock", "attempt", attempt)
 
+		// Peers may have come and gone since the last attempt, re-evaluate which of them can provide the block
+		if attempt > 1 {
+			newAvailables, err := mp.internals.BlockAvailability(folderID, file, block)
+			if err != nil {
+				return nil, err
+			}
+			availables = newAvailables
+			mp.sortByLatency(availables)
+		}
+
 		for _, available := range availables {
 			// Check if we were cancelled
 			if err := ctx.Err(); err != nil {
@@ -249,12 +316,45 @@ func (mp *miniPuller) downloadBlock(ctx context.Context, folderID string, blockI
 			}
 		}
 
+		stallTimeout := currentDownloadStallTimeout()
+		if !stalled && stallTimeout > 0 && time.Since(startTime) > stallTimeout {
+			stalled = true
+			slog.Warn("download stalled, waiting for a source", "blockIndex", blockIndex, "file", file.Name, "availablePeers", len(availables))
+			if mp.onStalled != nil {
+				mp.onStalled(true)
+			}
+		}
+
 		retryTime := time.Duration(700) * time.Millisecond
 		slog.Debug("waiting for retry", "retryTime", retryTime)
-		time.Sleep(retryTime)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryTime):
+		}
 	}
 }
 
+func (mp *miniPuller) sortByLatency(availables []model.Availability) {
+	slices.SortFunc(availables, func(a model.Availability, b model.Availability) int {
+		latencyA := mp.measurements.LatencyFor(a.ID.String())
+		latencyB := mp.measurements.LatencyFor(b.ID.String())
+		if math.IsNaN(latencyA) && math.IsNaN(latencyB) {
+			return 0
+		} else if math.IsNaN(latencyA) {
+			return 1 // a > b
+		} else if math.IsNaN(latencyB) {
+			return -1 // b > a
+		} else if latencyA > latencyB {
+			return 1
+		} else if latencyB > latencyA {
+			return -1
+		} else {
+			return 0
+		}
+	})
+}
+
 func newMiniPuller(measurements *Measurements, internals *syncthing.Internals) *miniPuller {
 	return &miniPuller{
 		experiences:  newExperiences(),
@@ -264,6 +364,11 @@ func newMiniPuller(measurements *Measurements, internals *syncthing.Internals) *
 }
 
 func (mp *miniPuller) downloadInto(ctx context.Context, w io.Writer, folderID string, info protocol.FileInfo) error {
+	return mp.downloadIntoFrom(ctx, w, folderID, info, 0)
+}
+
+// Download the blocks of the file starting at startBlock and write them to w in order
+func (mp *miniPuller) downloadIntoFrom(ctx context.Context, w io.Writer, folderID string, info protocol.FileInfo, startBlock int) error {
 	var wg sync.WaitGroup
 	parallellism := 2
 
@@ -279,7 +384,7 @@ func (mp *miniPuller) downloadInto(ctx context.Context, w io.Writer, folderID st
 			wg.Add(1)
 			defer wg.Done()
 
-			var i = threadIndex
+			var i = startBlock + threadIndex
 			for i < len(info.Blocks) {
 				// Check if we were cancelled
 				if err := ctx.Err(); err != nil {
@@ -305,14 +410,14 @@ func (mp *miniPuller) downloadInto(ctx context.Context, w io.Writer, folderID st
 	defer wg.Wait()
 
 	// Read the blocks in order
-	for blockNo, _ := range info.Blocks {
+	for blockNo := startBlock; blockNo < len(info.Blocks); blockNo++ {
 		select {
 		case err := <-errChan:
 			slog.Info("download into error", "cause", err)
 			cancel()
 			return err
 
-		case block := <-chans[blockNo%parallellism]:
+		case block := <-chans[(blockNo-startBlock)%parallellism]:
 			slog.Debug("download into write", "bytes", len(block))
 			_, err := w.Write(block)
 			if err != nil {
@@ -0,0 +1,42 @@
+package sushitrain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSetBlockCacheSize(t *testing.T) {
+	t.Cleanup(func() {
+		ClearBlockCache()
+		SetBlockCacheSize(defaultBlockCacheSize)
+	})
+	ClearBlockCache()
+
+	if err := SetBlockCacheSize(0); err == nil {
+		t.Error("expected an error for an empty cache")
+	}
+
+	if err := SetBlockCacheSize(4); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 6 {
+		blockCache.Add(fmt.Sprintf("block%d", i), []byte{byte(i)})
+	}
+	if stats := BlockCacheStats(); stats.Entries != 4 || stats.MaxEntries != 4 {
+		t.Errorf("unexpected cache statistics after filling: %+v", stats)
+	}
+
+	// Growing keeps all entries, shrinking keeps the most recently used ones
+	if err := SetBlockCacheSize(8); err != nil {
+		t.Fatal(err)
+	}
+	if blockCache.Len() != 4 {
+		t.Errorf("expected entries to be kept when growing, have %d", blockCache.Len())
+	}
+	if err := SetBlockCacheSize(2); err != nil {
+		t.Fatal(err)
+	}
+	if !blockCache.Contains("block5") || !blockCache.Contains("block4") || blockCache.Contains("block3") {
+		t.Errorf("expected most recently used entries to be kept, have %v", blockCache.Keys())
+	}
+}
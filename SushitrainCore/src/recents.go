@@ -0,0 +1,168 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"time"
+)
+
+const recentlyAccessedFileName = "recently-accessed.json"
+
+// Maximum number of access records that is kept
+const maxRecentlyAccessed = 100
+
+// Repeated access to the same file within this interval (e.g. range requests while streaming) is recorded only once
+const recentAccessCoalesceInterval = time.Minute
+
+// Changes to the recently accessed list are written to disk after this delay, so that a burst of accesses is saved once
+const recentlyAccessedSaveDelay = 5 * time.Second
+
+type AccessRecord struct {
+	FolderID string    `json:"folderID"`
+	Path     string    `json:"path"`
+	Time     time.Time `json:"time"`
+}
+
+func (rec *AccessRecord) AccessedAt() *Date {
+	return &Date{time: rec.Time}
+}
+
+type AccessRecordList struct {
+	records []*AccessRecord
+}
+
+func (lst *AccessRecordList) Count() int {
+	return len(lst.records)
+}
+
+func (lst *AccessRecordList) ItemAt(index int) *AccessRecord {
+	return lst.records[index]
+}
+
+func (clt *Client) recentlyAccessedPath() string {
+	return path.Join(clt.CurrentConfigDirectory(), recentlyAccessedFileName)
+}
+
+// Must be called with clt.mutex held
+func (clt *Client) loadRecentlyAccessedLocked() {
+	if clt.recentlyAccessed != nil {
+		return
+	}
+	clt.recentlyAccessed = make([]AccessRecord, 0)
+
+	data, err := os.ReadFile(clt.recentlyAccessedPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("could not read recently accessed list", "cause", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &clt.recentlyAccessed); err != nil {
+		slog.Warn("could not parse recently accessed list", "cause", err)
+		clt.recentlyAccessed = make([]AccessRecord, 0)
+	}
+}
+
+// Records that a file was streamed or downloaded, moving it to the front of the recently accessed list
+func (clt *Client) recordAccess(folderID string, path string) {
+	now := time.Now()
+
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	clt.loadRecentlyAccessedLocked()
+
+	if len(clt.recentlyAccessed) > 0 {
+		latest := clt.recentlyAccessed[0]
+		if latest.FolderID == folderID && latest.Path == path && now.Sub(latest.Time) < recentAccessCoalesceInterval {
+			return
+		}
+	}
+
+	records := slices.DeleteFunc(clt.recentlyAccessed, func(record AccessRecord) bool {
+		return record.FolderID == folderID && record.Path == path
+	})
+	records = slices.Insert(records, 0, AccessRecord{FolderID: folderID, Path: path, Time: now})
+	if len(records) > maxRecentlyAccessed {
+		records = records[:maxRecentlyAccessed]
+	}
+	clt.recentlyAccessed = records
+
+	if clt.recentlyAccessedSaveTimer == nil {
+		clt.recentlyAccessedSaveTimer = time.AfterFunc(recentlyAccessedSaveDelay, clt.saveRecentlyAccessed)
+	}
+}
+
+// Writes the recently accessed list to disk. Called from the timer started by recordAccess, without clt.mutex held.
+func (clt *Client) saveRecentlyAccessed() {
+	clt.recentlyAccessedSaveMutex.Lock()
+	defer clt.recentlyAccessedSaveMutex.Unlock()
+
+	clt.mutex.Lock()
+	clt.recentlyAccessedSaveTimer = nil
+	records := slices.Clone(clt.recentlyAccessed)
+	clt.mutex.Unlock()
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		slog.Warn("could not serialize recently accessed list", "cause", err)
+		return
+	}
+	if err := os.WriteFile(clt.recentlyAccessedPath(), data, 0o600); err != nil {
+		slog.Warn("could not save recently accessed list", "cause", err)
+	}
+}
+
+// Returns at most limit of the most recently streamed or downloaded files, most recent first
+func (clt *Client) recentlyAccessedRecords(limit int) []AccessRecord {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	clt.loadRecentlyAccessedLocked()
+
+	if limit < 0 || limit > len(clt.recentlyAccessed) {
+		limit = len(clt.recentlyAccessed)
+	}
+	return slices.Clone(clt.recentlyAccessed[:limit])
+}
+
+// Returns at most limit of the most recently streamed or downloaded files, most recent first
+func (clt *Client) RecentlyAccessed(limit int) *AccessRecordList {
+	records := clt.recentlyAccessedRecords(limit)
+	list := &AccessRecordList{records: make([]*AccessRecord, len(records))}
+	for idx := range records {
+		list.records[idx] = &records[idx]
+	}
+	return list
+}
+
+// Returns the result of RecentlyAccessed as a JSON array of records with folderID, path and time
+func (clt *Client) RecentlyAccessedJSON(limit int) ([]byte, error) {
+	return json.Marshal(clt.recentlyAccessedRecords(limit))
+}
+
+// Removes all records from the recently accessed list
+func (clt *Client) ClearRecentlyAccessed() error {
+	clt.mutex.Lock()
+	clt.recentlyAccessed = make([]AccessRecord, 0)
+	if clt.recentlyAccessedSaveTimer != nil {
+		clt.recentlyAccessedSaveTimer.Stop()
+		clt.recentlyAccessedSaveTimer = nil
+	}
+	clt.mutex.Unlock()
+
+	clt.recentlyAccessedSaveMutex.Lock()
+	defer clt.recentlyAccessedSaveMutex.Unlock()
+	if err := os.Remove(clt.recentlyAccessedPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
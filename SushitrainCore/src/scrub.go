@@ -0,0 +1,153 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+)
+
+const scrubReportsFileName = "scrub-reports.json"
+
+type ScrubDelegate interface {
+	// Called for each local file whose contents do not match the global index (e.g. because of bit rot)
+	OnDamaged(path string)
+	OnError(error string)
+	OnProgress(fraction float64)
+	OnFinished(report *ScrubReport)
+	IsCancelled() bool
+}
+
+// The outcome of a completed scrub of a folder
+type ScrubReport struct {
+	FinishedAt   time.Time `json:"finishedAt"`
+	CheckedFiles int       `json:"checkedFiles"`
+	DamagedPaths []string  `json:"damagedPaths"`
+}
+
+func (r *ScrubReport) Finished() *Date {
+	return &Date{time: r.FinishedAt}
+}
+
+func (r *ScrubReport) Checked() int {
+	return r.CheckedFiles
+}
+
+func (r *ScrubReport) Damaged() *ListOfStrings {
+	return List(r.DamagedPaths)
+}
+
+func (r *ScrubReport) IsClean() bool {
+	return len(r.DamagedPaths) == 0
+}
+
+// Adapts a ScrubDelegate to receive the results of verifyLocalFiles
+type scrubReporter struct {
+	delegate ScrubDelegate
+}
+
+func (s *scrubReporter) OnMismatch(path string) {
+	s.delegate.OnDamaged(path)
+}
+
+func (s *scrubReporter) OnError(err string) {
+	s.delegate.OnError(err)
+}
+
+func (s *scrubReporter) OnProgress(fraction float64) {
+	s.delegate.OnProgress(fraction)
+}
+
+func (s *scrubReporter) IsCancelled() bool {
+	return s.delegate.IsCancelled()
+}
+
+var _ verifyReporter = &scrubReporter{}
+
+func (clt *Client) scrubReportsPath() string {
+	return path.Join(clt.CurrentConfigDirectory(), scrubReportsFileName)
+}
+
+// Returns the last scrub report per folder ID. Should be called with the mutex held.
+func (clt *Client) loadScrubReportsLocked() (map[string]*ScrubReport, error) {
+	reports := make(map[string]*ScrubReport)
+	data, err := os.ReadFile(clt.scrubReportsPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return reports, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (clt *Client) saveScrubReport(folderID string, report *ScrubReport) error {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	reports, err := clt.loadScrubReportsLocked()
+	if err != nil {
+		// Start over rather than never being able to save a report again
+		slog.Warn("could not read scrub reports", "cause", err)
+		reports = make(map[string]*ScrubReport)
+	}
+	reports[folderID] = report
+
+	data, err := json.Marshal(reports)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(clt.scrubReportsPath(), data, 0o600)
+}
+
+// Checks the integrity of all locally present files in the folder by hashing them and comparing against the global
+// index (see VerifyLocalFiles), and reports files that are damaged. Unlike VerifyLocalFiles, nothing is repaired. When
+// the scrub completes, its report is saved and can later be retrieved using LastScrubReport. A cancelled scrub does not
+// produce a report.
+func (fld *Folder) Scrub(delegate ScrubDelegate) {
+	go func() {
+		checked, mismatches, cancelled, err := fld.verifyLocalFiles(&scrubReporter{delegate: delegate})
+		if err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+		if cancelled {
+			return
+		}
+
+		report := &ScrubReport{
+			FinishedAt:   time.Now(),
+			CheckedFiles: checked,
+			DamagedPaths: Map(mismatches, func(entry *Entry) string {
+				return entry.Path()
+			}),
+		}
+		if err := fld.client.saveScrubReport(fld.FolderID, report); err != nil {
+			slog.Warn("could not save scrub report", "folderID", fld.FolderID, "cause", err)
+		}
+		delegate.OnFinished(report)
+	}()
+}
+
+// Returns the report of the last completed scrub of this folder, or nil when the folder was never scrubbed
+func (fld *Folder) LastScrubReport() (*ScrubReport, error) {
+	fld.client.mutex.Lock()
+	defer fld.client.mutex.Unlock()
+
+	reports, err := fld.client.loadScrubReportsLocked()
+	if err != nil {
+		return nil, err
+	}
+	return reports[fld.FolderID], nil
+}
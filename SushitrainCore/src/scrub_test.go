@@ -0,0 +1,41 @@
+package sushitrain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/locations"
+)
+
+func TestScrubReportPersistence(t *testing.T) {
+	previousConfigDir := locations.GetBaseDir(locations.ConfigBaseDir)
+	t.Cleanup(func() {
+		locations.SetBaseDir(locations.ConfigBaseDir, previousConfigDir)
+	})
+	if err := locations.SetBaseDir(locations.ConfigBaseDir, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	clt := &Client{}
+	fld := &Folder{client: clt, FolderID: "abc"}
+
+	if report, err := fld.LastScrubReport(); err != nil || report != nil {
+		t.Fatalf("expected no report before scrubbing: %v, %v", report, err)
+	}
+
+	finished := time.Unix(1700000000, 0)
+	if err := clt.saveScrubReport("abc", &ScrubReport{FinishedAt: finished, CheckedFiles: 3, DamagedPaths: []string{"a/b.jpg"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clt.saveScrubReport("other", &ScrubReport{FinishedAt: finished, CheckedFiles: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := fld.LastScrubReport()
+	if err != nil || report == nil {
+		t.Fatalf("expected a report: %v", err)
+	}
+	if !report.FinishedAt.Equal(finished) || report.Checked() != 3 || report.IsClean() || report.Damaged().Count() != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
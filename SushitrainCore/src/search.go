@@ -0,0 +1,322 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"bytes"
+	"container/heap"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Maximum number of results kept by SearchRanked when no (or an invalid) maximum is given
+const defaultMaxRankedSearchResults = 1000
+
+// Maximum size of files searched by SearchContents when no (or an invalid) maximum is given
+const defaultMaxContentSearchFileSize = 1024 * 1024
+
+// Files with these MIME types (or prefixes) are considered text files by SearchContents
+var searchableContentMIMETypePrefixes = []string{
+	"text/",
+	"application/json",
+	"application/ld+json",
+	"application/xml",
+}
+
+const (
+	searchScoreSubstring = 1000
+	searchScorePrefix    = 2000
+	searchScoreExact     = 3000
+
+	// Bonus for shallow paths, decreasing by searchScoreDepthPenalty for every directory level
+	searchScoreMaxDepthBonus = 100
+	searchScoreDepthPenalty  = 10
+)
+
+// Matches file names against a set of file type categories and extensions
+type searchFileTypeFilter struct {
+	categories []string
+	extensions []string // Lowercase, including the dot
+}
+
+func newSearchFileTypeFilter(fileTypes *ListOfStrings) *searchFileTypeFilter {
+	filter := &searchFileTypeFilter{
+		categories: make([]string, 0),
+		extensions: make([]string, 0),
+	}
+	if fileTypes == nil {
+		return filter
+	}
+
+	for _, fileType := range fileTypes.data {
+		fileType = strings.ToLower(strings.TrimSpace(fileType))
+		switch fileType {
+		case "":
+			continue
+		case FileTypeCategoryImage, FileTypeCategoryVideo, FileTypeCategoryAudio, FileTypeCategoryDocument, FileTypeCategoryOther:
+			filter.categories = append(filter.categories, fileType)
+		default:
+			if !strings.HasPrefix(fileType, ".") {
+				fileType = "." + fileType
+			}
+			filter.extensions = append(filter.extensions, fileType)
+		}
+	}
+	return filter
+}
+
+// Returns true when the file name has one of the extensions or falls in one of the categories, or the filter is empty
+func (filter *searchFileTypeFilter) matches(fileName string) bool {
+	if len(filter.categories) == 0 && len(filter.extensions) == 0 {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext != "" && slices.Contains(filter.extensions, ext) {
+		return true
+	}
+	return len(filter.categories) > 0 && slices.Contains(filter.categories, FileTypeCategoryForExtension(ext))
+}
+
+type rankedSearchResult struct {
+	folderID string
+	path     string
+	score    int
+}
+
+// Returns true when a ranks below b. Equal scores are ordered by path so that results are stable.
+func (a rankedSearchResult) isWorseThan(b rankedSearchResult) bool {
+	if a.score != b.score {
+		return a.score < b.score
+	}
+	if a.path != b.path {
+		return a.path > b.path
+	}
+	return a.folderID > b.folderID
+}
+
+// Scores the file at path for the (lowercased) search text. Returns zero when the file name does not match.
+func searchScore(lowerText string, path string) int {
+	pathParts := strings.Split(path, "/")
+	lowerFileName := strings.ToLower(pathParts[len(pathParts)-1])
+
+	score := 0
+	switch {
+	case lowerFileName == lowerText:
+		score = searchScoreExact
+	case strings.HasPrefix(lowerFileName, lowerText):
+		score = searchScorePrefix
+	case strings.Contains(lowerFileName, lowerText):
+		score = searchScoreSubstring
+	default:
+		return 0
+	}
+
+	depth := len(pathParts) - 1
+	return score + max(0, searchScoreMaxDepthBonus-depth*searchScoreDepthPenalty)
+}
+
+// Min-heap of search results, the worst result is at the top so it can be evicted when a better one comes along
+type rankedSearchResults struct {
+	results    []rankedSearchResult
+	maxResults int
+}
+
+func newRankedSearchResults(maxResults int) *rankedSearchResults {
+	if maxResults <= 0 {
+		maxResults = defaultMaxRankedSearchResults
+	}
+	return &rankedSearchResults{
+		results:    make([]rankedSearchResult, 0),
+		maxResults: maxResults,
+	}
+}
+
+func (r *rankedSearchResults) Len() int           { return len(r.results) }
+func (r *rankedSearchResults) Less(i, j int) bool { return r.results[i].isWorseThan(r.results[j]) }
+func (r *rankedSearchResults) Swap(i, j int)      { r.results[i], r.results[j] = r.results[j], r.results[i] }
+func (r *rankedSearchResults) Push(x any)         { r.results = append(r.results, x.(rankedSearchResult)) }
+func (r *rankedSearchResults) Pop() any {
+	last := r.results[len(r.results)-1]
+	r.results = r.results[:len(r.results)-1]
+	return last
+}
+
+func (r *rankedSearchResults) add(result rankedSearchResult) {
+	if len(r.results) < r.maxResults {
+		heap.Push(r, result)
+	} else if r.results[0].isWorseThan(result) {
+		r.results[0] = result
+		heap.Fix(r, 0)
+	}
+}
+
+// Returns the results best-first
+func (r *rankedSearchResults) sorted() []rankedSearchResult {
+	sorted := slices.Clone(r.results)
+	slices.SortFunc(sorted, func(a rankedSearchResult, b rankedSearchResult) int {
+		if a.isWorseThan(b) {
+			return 1
+		} else if b.isWorseThan(a) {
+			return -1
+		}
+		return 0
+	})
+	return sorted
+}
+
+/*
+* Search for files by name in the global index, like Search, but ranks results by relevance: an exact file name match
+ranks above a match at the start of the file name, which ranks above a match anywhere in the file name. Files in shallower
+directories rank higher. Only the best `maxResults` results are kept (1000 when maxResults is <=0). These are delivered to
+the delegate best-first after the scan completes or is cancelled.
+*/
+func (clt *Client) SearchRanked(text string, delegate SearchResultDelegate, maxResults int, folderID string, prefix string) error {
+	if clt.app == nil || clt.app.Internals == nil {
+		return ErrStillLoading
+	}
+
+	text = strings.ToLower(text)
+	results := newRankedSearchResults(maxResults)
+
+scan:
+	for _, folder := range clt.config.FolderList() {
+		if folderID != "" && folder.ID != folderID {
+			continue
+		}
+
+		for f, err := range zipError(clt.app.Internals.AllGlobalFiles(folder.ID)) {
+			if err != nil {
+				return err
+			}
+
+			if delegate.IsCancelled() {
+				break scan
+			}
+
+			if f.Deleted || !strings.HasPrefix(f.Name, prefix) {
+				continue
+			}
+
+			if score := searchScore(text, f.Name); score > 0 {
+				results.add(rankedSearchResult{folderID: folder.ID, path: f.Name, score: score})
+			}
+		}
+	}
+
+	for _, result := range results.sorted() {
+		folderObject := Folder{
+			client:   clt,
+			FolderID: result.folderID,
+		}
+		entry, err := folderObject.GetFileInformation(result.path)
+		if err == nil {
+			delegate.Result(entry)
+		}
+	}
+	return nil
+}
+
+func isSearchableContentMIMEType(mimeType string) bool {
+	return slices.ContainsFunc(searchableContentMIMETypePrefixes, func(prefix string) bool {
+		return strings.HasPrefix(mimeType, prefix)
+	})
+}
+
+// Returns true when the data contains the (lowercased) search text. Data that looks binary never matches.
+func contentMatches(data []byte, lowerText string) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), lowerText)
+}
+
+/*
+* Search for text files that contain the specified text (case-insensitively). Only files that are present locally, have a
+text MIME type and are at most `maxFileSize` bytes (1 MiB when maxFileSize is <=0) are searched. Files that turn out to
+contain binary data are skipped. At most `maxResults` results are delivered (no limit when maxResults is <=0).
+*/
+func (clt *Client) SearchContents(text string, delegate SearchResultDelegate, maxResults int, folderID string, maxFileSize int64) error {
+	if clt.app == nil || clt.app.Internals == nil {
+		return ErrStillLoading
+	}
+	if text == "" {
+		return nil
+	}
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxContentSearchFileSize
+	}
+
+	text = strings.ToLower(text)
+	resultCount := 0
+
+	for _, folder := range clt.config.FolderList() {
+		if folderID != "" && folder.ID != folderID {
+			continue
+		}
+
+		folderObject := Folder{
+			client:   clt,
+			FolderID: folder.ID,
+		}
+		ffs := folder.Filesystem()
+
+		for f, err := range zipError(clt.app.Internals.AllGlobalFiles(folder.ID)) {
+			if err != nil {
+				return err
+			}
+
+			if delegate.IsCancelled() || (maxResults > 0 && resultCount >= maxResults) {
+				return nil
+			}
+
+			if f.Deleted || f.Type != protocol.FileInfoTypeFile || f.Size > maxFileSize {
+				continue
+			}
+			if !isSearchableContentMIMEType(MIMETypeForExtension(filepath.Ext(f.Name))) {
+				continue
+			}
+
+			// Skip files that are not (or not in this version) present locally
+			nativeName := osutil.NativeFilename(f.Name)
+			stat, err := ffs.Lstat(nativeName)
+			if err != nil || !stat.IsRegular() || stat.Size() > maxFileSize {
+				continue
+			}
+
+			data, err := readFileFromFilesystem(ffs, nativeName)
+			if err != nil {
+				slog.Debug("could not read file for content search", "folderID", folder.ID, "path", f.Name, "cause", err)
+				continue
+			}
+
+			if contentMatches(data, text) {
+				entry, err := folderObject.GetFileInformation(f.Name)
+				if err == nil && entry != nil {
+					resultCount += 1
+					delegate.Result(entry)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func readFileFromFilesystem(ffs fs.Filesystem, name string) ([]byte, error) {
+	fd, err := ffs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return io.ReadAll(fd)
+}
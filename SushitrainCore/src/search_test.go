@@ -0,0 +1,90 @@
+package sushitrain
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchScore(t *testing.T) {
+	exact := searchScore("report", "docs/Report")
+	prefix := searchScore("report", "docs/report-2024.pdf")
+	substring := searchScore("report", "docs/annual-report.pdf")
+	if !(exact > prefix && prefix > substring && substring > 0) {
+		t.Errorf("unexpected ordering of scores: exact=%d prefix=%d substring=%d", exact, prefix, substring)
+	}
+
+	if searchScore("report", "report/other.pdf") != 0 {
+		t.Errorf("directory names should not match")
+	}
+
+	if searchScore("report", "report.pdf") <= searchScore("report", "a/b/report.pdf") {
+		t.Errorf("shallower paths should rank higher")
+	}
+}
+
+func TestRankedSearchResultsKeepsBest(t *testing.T) {
+	results := newRankedSearchResults(3)
+	for _, path := range []string{"a/b/c/report.txt", "x-report.txt", "report", "report.txt", "deep/er/report", "old-report"} {
+		if score := searchScore("report", path); score > 0 {
+			results.add(rankedSearchResult{folderID: "f", path: path, score: score})
+		}
+	}
+
+	paths := make([]string, 0)
+	for _, result := range results.sorted() {
+		paths = append(paths, result.path)
+	}
+
+	expected := []string{"report", "deep/er/report", "report.txt"}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("unexpected results %v, expected %v", paths, expected)
+	}
+}
+
+func TestSearchFileTypeFilter(t *testing.T) {
+	cases := []struct {
+		fileTypes []string
+		fileName  string
+		matches   bool
+	}{
+		{nil, "anything.xyz", true},
+		{[]string{}, "anything", true},
+		{[]string{"pdf"}, "Report.PDF", true},
+		{[]string{".docx", "pdf"}, "letter.docx", true},
+		{[]string{"pdf"}, "letter.docx", false},
+		{[]string{"image"}, "photo.jpg", true},
+		{[]string{"image"}, "movie.mp4", false},
+		{[]string{"image", "video"}, "movie.mp4", true},
+		{[]string{"document"}, "notes.txt", true},
+		{[]string{"pdf"}, "pdf", false},
+	}
+
+	for _, c := range cases {
+		var fileTypes *ListOfStrings
+		if c.fileTypes != nil {
+			fileTypes = List(c.fileTypes)
+		}
+		if matches := newSearchFileTypeFilter(fileTypes).matches(c.fileName); matches != c.matches {
+			t.Errorf("filter %v on %q: got %t, expected %t", c.fileTypes, c.fileName, matches, c.matches)
+		}
+	}
+}
+
+func TestContentMatches(t *testing.T) {
+	if !contentMatches([]byte("Shopping list:\nMilk\nEggs"), "eggs") {
+		t.Error("should match case-insensitively")
+	}
+	if contentMatches([]byte("Shopping list"), "eggs") {
+		t.Error("should not match absent text")
+	}
+	if contentMatches([]byte("eggs\x00\x01\x02"), "eggs") {
+		t.Error("binary data should not match")
+	}
+
+	if !isSearchableContentMIMEType(MIMETypeForExtension(".md")) || !isSearchableContentMIMEType(MIMETypeForExtension(".json")) {
+		t.Error("markdown and JSON should be searchable")
+	}
+	if isSearchableContentMIMEType(MIMETypeForExtension(".jpg")) {
+		t.Error("images should not be searchable")
+	}
+}
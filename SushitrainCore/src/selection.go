@@ -38,49 +38,153 @@ func isCommentPattern(pattern string) bool {
 	return len(pattern) == 0 || strings.HasPrefix(pattern, "//")
 }
 
+func isIncludePattern(pattern string) bool {
+	return strings.HasPrefix(pattern, "#include ")
+}
+
+// Lines that have no meaning for the selection, and that are kept in place when the selection is edited
+func isPassThroughPattern(pattern string) bool {
+	return isCommentPattern(pattern) || isIncludePattern(pattern)
+}
+
+// Removes selection patterns that are duplicates of, or nested in, other selection patterns. Other lines (global ignores,
+// comments, blank lines and includes) are kept in their original positions.
 func cleanSelectiveSelection(lines []string) ([]string, error) {
-	result := make([]string, 0)
+	result := make([]string, 0, len(lines))
 
 	// First, find all selection patterns
-	selectionPatterns := make([]string, 0)
+	selectionPatterns := Filter(lines, isSelectionPattern)
 
 	for idx, line := range lines {
 		if idx == len(lines)-1 && line == "*" {
 			continue
 		} else if isSelectionPattern(line) {
-			selectionPatterns = append(selectionPatterns, line)
-		} else if isGlobalIgnorePattern(line) {
+			// Note: given an existing selection of "/a/some", a new line "/a/something" is *not* implicitly selected,
+			// but "/a/some/thing" is.
+			parent := slices.IndexFunc(selectionPatterns, func(otherLine string) bool {
+				return otherLine != line && strings.HasPrefix(line, otherLine) && strings.Contains(line[len(otherLine):], "/")
+			})
+
+			if parent >= 0 {
+				// Some other line has this line as a prefix; skip it
+				slog.Warn("selection contains a path that is also selected by a parent; removing", "prefix", selectionPatterns[parent], "path", line)
+				continue
+			}
+			if slices.Contains(result, line) {
+				slog.Warn("selection contains a path more than once; removing", "path", line)
+				continue
+			}
+			result = append(result, line)
+		} else if isGlobalIgnorePattern(line) || isPassThroughPattern(line) {
 			result = append(result, line)
-		} else if isCommentPattern(line) {
-			// Throw these out
-			continue
 		} else {
 			return nil, fmt.Errorf("invalid pattern: %s", line)
 		}
 	}
 
-	slices.Sort(selectionPatterns)
+	result = append(result, "*")
+	return result, nil
+}
 
-	// Write new ignore lines for selection patterns
+// Turns a set of ignore lines that was meant to be selective, but is no longer valid (e.g. after manual edits) into a
+// valid selective ignore file. Selections and global ignores are retained, duplicates and nested selections are
+// removed, and other patterns are dropped. Comments, blank lines and includes are kept in place. Global ignores that
+// appear after a selection are moved to just before the first selection.
+func repairedSelectiveLines(lines []string) []string {
+	// Normalize lines first, so that nested selections can be found below
+	normalized := make([]string, 0, len(lines))
+	selections := make([]string, 0)
 	for _, line := range lines {
-		if isSelectionPattern(line) {
-			// Note: given an existing selection of "/a/some", a new line "/a/something" is *not* implicitly selected,
-			// but "/a/some/thing" is.
-			foundIndex := slices.IndexFunc(selectionPatterns, func(otherLine string) bool {
+		line = strings.TrimRight(line, " \r\t")
+		if line == "*" {
+			continue
+		}
+
+		if isPassThroughPattern(line) || isGlobalIgnorePattern(line) {
+			normalized = append(normalized, line)
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "!"); ok && !strings.Contains(rest, "*") {
+			// Selections must be rooted and should not end in a slash
+			line = "!/" + strings.Trim(rest, "/")
+			if line != "!/" {
+				normalized = append(normalized, line)
+				selections = append(selections, line)
+			}
+			continue
+		}
+
+		slog.Warn("dropping pattern that is not valid in a selective folder", "line", line)
+	}
+
+	result := make([]string, 0, len(normalized)+1)
+	firstSelection := -1
+	for _, line := range normalized {
+		switch {
+		case isPassThroughPattern(line):
+			result = append(result, line)
+
+		case isGlobalIgnorePattern(line):
+			if slices.Contains(result, line) {
+				continue
+			}
+			if firstSelection >= 0 {
+				// Global ignores must precede all selections
+				result = slices.Insert(result, firstSelection, line)
+				firstSelection++
+			} else {
+				result = append(result, line)
+			}
+
+		default:
+			isNested := slices.ContainsFunc(selections, func(otherLine string) bool {
 				return otherLine != line && strings.HasPrefix(line, otherLine) && strings.Contains(line[len(otherLine):], "/")
 			})
-
-			if foundIndex >= 0 {
-				// Some other line has this line as a prefix; skip it
-				slog.Warn("selection contains a path that is also selected by a parent; removing", "prefix", lines[foundIndex], "path", line)
+			if isNested || slices.Contains(result, line) {
 				continue
 			}
+			if firstSelection < 0 {
+				firstSelection = len(result)
+			}
 			result = append(result, line)
 		}
 	}
 
-	result = append(result, "*")
-	return result, nil
+	return append(result, "*")
+}
+
+func (sel *selection) repair() {
+	sel.lines = repairedSelectiveLines(sel.lines)
+	if !sel.isSelectiveIgnore() {
+		panic("ignore file is not selective after repair")
+	}
+}
+
+// Rewrites selection lines for a path that was moved (and for paths inside it, when it is a directory)
+func (sel *selection) renameSelectedPath(fromPath string, toPath string) {
+	lines := make([]string, 0, len(sel.lines))
+	for _, line := range sel.lines {
+		if isSelectionPattern(line) {
+			path := pathForIgnoreLine(line)
+			if path == fromPath {
+				line = ignoreLineForSelectingPath(toPath)
+			} else if rest, ok := strings.CutPrefix(path, fromPath+"/"); ok {
+				line = ignoreLineForSelectingPath(toPath + "/" + rest)
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	// The new path may be nested in (or be the same as) another selected path
+	cleaned, err := cleanSelectiveSelection(lines)
+	if err != nil {
+		// Only happens when the selection was not valid to begin with
+		sel.lines = lines
+		sel.repair()
+		return
+	}
+	sel.lines = cleaned
 }
 
 func (sel *selection) setSelective(selective bool) error {
@@ -91,8 +195,9 @@ func (sel *selection) setSelective(selective bool) error {
 		return nil
 	}
 
+	// Keep global ignores as well as comments and includes added by the user
 	newLines := Filter(sel.lines, func(line string) bool {
-		return isGlobalIgnorePattern(line)
+		return isGlobalIgnorePattern(line) || isPassThroughPattern(line)
 	})
 
 	if selective {
@@ -129,7 +234,7 @@ func (sel *selection) isSelectiveIgnore() bool {
 				return false
 			}
 		} else {
-			if isCommentPattern(pattern) {
+			if isPassThroughPattern(pattern) {
 				continue
 			} else if pattern[0] == '!' {
 				// Allow patterns that start with '!/' and disallow global ignore patterns from that point onwards
@@ -179,13 +284,19 @@ func (sel *selection) setGlobalIgnorePatterns(patterns []string) error {
 		}
 	}
 
-	// Build new list of patterns; start with the new global ignores, then append the existing patterns *except* the
-	// old global ignores
+	// Build new list of patterns; the new global ignores take the place of the old global ignores (or go at the start
+	// when there were none), other lines are kept where they are
 	newSel := make([]string, 0)
-	newSel = append(newSel, patterns...)
+	inserted := !slices.ContainsFunc(sel.lines, isGlobalIgnorePattern)
+	if inserted {
+		newSel = append(newSel, patterns...)
+	}
 	for _, pattern := range sel.lines {
 		if !isGlobalIgnorePattern(pattern) {
 			newSel = append(newSel, pattern)
+		} else if !inserted {
+			newSel = append(newSel, patterns...)
+			inserted = true
 		}
 	}
 
@@ -281,7 +392,7 @@ func (sel *selection) setExplicitlySelected(paths map[string]bool) error {
 		// Note: given an existing selection of "/a/some", a new line "/a/something" is *not* implicitly selected,
 		// but "/a/some/thing" is.
 		currentlySelectedImplicitly := slices.ContainsFunc(newLines, func(existingLine string) bool {
-			return existingLine != line && strings.HasPrefix(line, existingLine) && strings.Contains(line[len(existingLine):], "/")
+			return isSelectionPattern(existingLine) && existingLine != line && strings.HasPrefix(line, existingLine) && strings.Contains(line[len(existingLine):], "/")
 		})
 
 		if currentlySelectedImplicitly {
@@ -313,7 +424,7 @@ func (sel *selection) setExplicitlySelected(paths map[string]bool) error {
 				return fmt.Errorf("failed to remove ignore line '%s'", line)
 			}
 		} else {
-			// To select, append it (but before the last '*')
+			// To select, append it (but before the last '*'). Other lines stay where they are.
 			newLines = append(newLines[:len(newLines)-1], line, "*")
 		}
 	}
@@ -392,3 +503,50 @@ func pathForIgnoreLine(line string) string {
 	}
 	return line
 }
+
+// Translates the contents of a .gitignore file to Syncthing ignore patterns. In Git the last matching pattern wins,
+// whereas in Syncthing the first one does, hence the order of the patterns is reversed. Comments are dropped.
+func ignorePatternsFromGitignore(content string) []string {
+	patterns := make([]string, 0)
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+
+		// Trailing spaces are ignored unless escaped
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negated := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(line, "!")
+
+		// Syncthing cannot express directory-only patterns; the pattern will also match files with the same name
+		line = strings.TrimSuffix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "**/"); ok {
+			// Syncthing already matches unrooted patterns at any level
+			line = rest
+		} else if strings.Contains(line, "/") && !strings.HasPrefix(line, "/") {
+			// In Git, a slash at the start or in the middle of a pattern makes it relative to the root
+			line = "/" + line
+		}
+
+		// Braces are used for alternatives in Syncthing patterns, but are literal characters in Git
+		line = strings.ReplaceAll(line, "{", "\\{")
+		line = strings.ReplaceAll(line, "}", "\\}")
+
+		if negated {
+			line = "!" + line
+		}
+		patterns = append(patterns, line)
+	}
+
+	slices.Reverse(patterns)
+	return patterns
+}
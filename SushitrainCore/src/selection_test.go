@@ -100,3 +100,152 @@ func TestChanges(t *testing.T) {
 		t.Errorf("file is not selective ignore after change 4 but it should be")
 	}
 }
+
+func TestIgnorePatternsFromGitignore(t *testing.T) {
+	gitignore := "# Build output\n/build/\nnode_modules/\n*.log\n!important.log\ndocs/*.md\n**/cache\n\n{a}.txt  \n"
+	expected := []string{"\\{a\\}.txt", "cache", "/docs/*.md", "!important.log", "*.log", "node_modules", "/build"}
+
+	patterns := ignorePatternsFromGitignore(gitignore)
+	if !slices.Equal(patterns, expected) {
+		t.Errorf("mismatch: %s %s", patterns, expected)
+	}
+}
+
+func TestRepairSelection(t *testing.T) {
+	beforeAfter := [][][]string{
+		{{"!/a", "!/a", "*"}, {"!/a", "*"}},
+		{{"!/b", "!/a"}, {"!/b", "!/a", "*"}},
+		{{"!/a", "(?d).DS_Store", "!/a/b", "*", "!/c"}, {"(?d).DS_Store", "!/a", "!/c", "*"}},
+		{{"// comment", "!a/", "!/b/", "!/", "*.tmp", "!/x/*", "*"}, {"// comment", "!/a", "!/b", "*"}},
+		{
+			{"#include .stglobalignore", "", "// Photos", "!/photos", "(?d).DS_Store", "", "// Documents", "!/docs/", "!/photos/2024", "*"},
+			{"#include .stglobalignore", "", "// Photos", "(?d).DS_Store", "!/photos", "", "// Documents", "!/docs", "*"},
+		},
+		{{"(?d).DS_Store", "(?d).DS_Store", "!/a \r"}, {"(?d).DS_Store", "!/a", "*"}},
+		{{}, {"*"}},
+	}
+
+	for _, ba := range beforeAfter {
+		sel := newSelection(ba[0])
+		sel.repair()
+		if !slices.Equal(sel.lines, ba[1]) {
+			t.Errorf("mismatch after repair of %q: %q, expected %q", ba[0], sel.lines, ba[1])
+		}
+		if !sel.isSelectiveIgnore() {
+			t.Errorf("not selective after repair: %q", sel.lines)
+		}
+		if reloaded := newSelection(slices.Clone(sel.lines)); !slices.Equal(reloaded.lines, ba[1]) {
+			t.Errorf("repaired lines changed after reloading: %q", reloaded.lines)
+		}
+	}
+}
+
+func TestRenameSelectedPath(t *testing.T) {
+	sel := newSelection([]string{"(?d).DS_Store", "!/a/file.txt", "!/b", "!/dir/x", "!/dir/y/z", "!/other", "*"})
+
+	sel.renameSelectedPath("a/file.txt", "a/renamed.txt")
+	expected := []string{"(?d).DS_Store", "!/a/renamed.txt", "!/b", "!/dir/x", "!/dir/y/z", "!/other", "*"}
+	if !slices.Equal(sel.lines, expected) {
+		t.Errorf("unexpected lines after renaming file: %q", sel.lines)
+	}
+
+	sel.renameSelectedPath("dir", "moved")
+	expected = []string{"(?d).DS_Store", "!/a/renamed.txt", "!/b", "!/moved/x", "!/moved/y/z", "!/other", "*"}
+	if !slices.Equal(sel.lines, expected) {
+		t.Errorf("unexpected lines after renaming directory: %q", sel.lines)
+	}
+
+	// Moving into a selected directory makes the explicit selection redundant
+	sel.renameSelectedPath("other", "b/other")
+	expected = []string{"(?d).DS_Store", "!/a/renamed.txt", "!/b", "!/moved/x", "!/moved/y/z", "*"}
+	if !slices.Equal(sel.lines, expected) {
+		t.Errorf("unexpected lines after moving into selected directory: %q", sel.lines)
+	}
+}
+
+func TestSelectionPreservesComments(t *testing.T) {
+	stignore := []string{
+		"// Managed by Synctrain",
+		"#include .stglobalignore",
+		"",
+		"(?d).DS_Store",
+		"// Selected paths",
+		"!/a",
+		"",
+		"!/b/c",
+		"// Keep everything else out",
+		"*",
+	}
+
+	sel := newSelection(slices.Clone(stignore))
+	if !sel.isSelectiveIgnore() {
+		t.Fatalf("file with comments and includes is not selective: %q", sel.lines)
+	}
+	if !slices.Equal(sel.lines, stignore) {
+		t.Errorf("lines changed by loading: %q", sel.lines)
+	}
+
+	err := sel.setExplicitlySelected(map[string]bool{"d": true, "b/c": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"// Managed by Synctrain",
+		"#include .stglobalignore",
+		"",
+		"(?d).DS_Store",
+		"// Selected paths",
+		"!/a",
+		"",
+		"// Keep everything else out",
+		"!/d",
+		"*",
+	}
+	if !slices.Equal(sel.lines, expected) {
+		t.Errorf("unexpected lines after selection change: %q", sel.lines)
+	}
+
+	// Blank lines must not make paths implicitly selected
+	err = sel.setExplicitlySelected(map[string]bool{"d": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sel.setGlobalIgnorePatterns([]string{"(?d)*.tmp", "(?d).DS_Store"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"// Managed by Synctrain",
+		"#include .stglobalignore",
+		"",
+		"(?d)*.tmp",
+		"(?d).DS_Store",
+		"// Selected paths",
+		"!/a",
+		"",
+		"// Keep everything else out",
+		"*",
+	}
+	if !slices.Equal(sel.lines, expected) {
+		t.Errorf("unexpected lines after changing global ignores: %q", sel.lines)
+	}
+
+	// Round trip through a new selection
+	if reloaded := newSelection(slices.Clone(sel.lines)); !slices.Equal(reloaded.lines, expected) {
+		t.Errorf("lines changed after reloading: %q", reloaded.lines)
+	}
+
+	sel.renameSelectedPath("a", "z")
+	if !slices.Contains(sel.lines, "// Selected paths") || !slices.Contains(sel.lines, "!/z") {
+		t.Errorf("unexpected lines after rename: %q", sel.lines)
+	}
+
+	if err := sel.setSelective(false); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"// Managed by Synctrain", "#include .stglobalignore", "", "(?d)*.tmp", "(?d).DS_Store", "// Selected paths", "", "// Keep everything else out"}
+	if !slices.Equal(sel.lines, expected) {
+		t.Errorf("unexpected lines after making non-selective: %q", sel.lines)
+	}
+}
@@ -8,14 +8,21 @@ package sushitrain
 import (
 	"context"
 	"crypto/ed25519"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -25,6 +32,10 @@ import (
 
 type StreamingServerDelegate interface {
 	OnStreamChunk(folder string, path string, bytesSent int64, bytesTotal int64)
+
+	// Called with true when fetching a block for a stream stalls (no peer could provide it within the download stall
+	// timeout, see SetDownloadStallTimeoutSeconds), and with false when the stalled block fetch ends
+	OnStreamStalled(folder string, path string, stalled bool)
 }
 
 type StreamingServer struct {
@@ -35,14 +46,114 @@ type StreamingServer struct {
 	MaxMbitsPerSecondsStreaming int64
 	mux                         *http.ServeMux
 	Delegate                    StreamingServerDelegate
+
+	// When set to a value above zero, stop fetching blocks for a stream when this many bytes have been delivered beyond
+	// the playback position reported through SetPlaybackPosition, until playback catches up
+	MaxBytesBufferedAhead int64
+	playbackPositions     map[string]int64 // streamKey(folder, path) => byte offset
+	playbackMutex         sync.Mutex
+
+	// When set, files requested through a transcoding URL (see Entry.TranscodingURL) are converted to a format that can be
+	// played natively. Files that can already be played are served as-is.
+	Transcoder StreamTranscoder
+
+	// Semaphore limiting the number of requests served simultaneously (nil when unlimited)
+	requestSlots chan struct{}
+	slotsMutex   sync.Mutex
+}
+
+// Limit the number of requests that are served simultaneously. Excess requests wait for a slot to become available (or
+// until the client gives up). Set to zero or less to remove the limit. Requests already being served are not affected.
+func (srv *StreamingServer) SetMaxConcurrentRequests(n int) {
+	srv.slotsMutex.Lock()
+	defer srv.slotsMutex.Unlock()
+
+	if n <= 0 {
+		srv.requestSlots = nil
+	} else {
+		srv.requestSlots = make(chan struct{}, n)
+	}
+}
+
+// Waits for a request slot to become available. Returns a function that releases the slot, or an error when the context
+// was cancelled while waiting.
+func (srv *StreamingServer) acquireRequestSlot(ctx context.Context) (func(), error) {
+	srv.slotsMutex.Lock()
+	slots := srv.requestSlots
+	srv.slotsMutex.Unlock()
+
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func streamKey(folder string, path string) string {
+	return folder + "\x00" + path
+}
+
+// Inform the server of the (approximate) byte offset in the file that the player is currently playing. Pass a negative
+// value when playback has ended, to stop buffer-based throttling for the file.
+func (srv *StreamingServer) SetPlaybackPosition(folder string, path string, position int64) {
+	srv.playbackMutex.Lock()
+	defer srv.playbackMutex.Unlock()
+
+	if position < 0 {
+		delete(srv.playbackPositions, streamKey(folder, path))
+	} else {
+		srv.playbackPositions[streamKey(folder, path)] = position
+	}
+}
+
+func (srv *StreamingServer) playbackPosition(folder string, path string) (int64, bool) {
+	srv.playbackMutex.Lock()
+	defer srv.playbackMutex.Unlock()
+	position, ok := srv.playbackPositions[streamKey(folder, path)]
+	return position, ok
+}
+
+// Blocks while more than MaxBytesBufferedAhead bytes have been delivered beyond the playback position
+func (srv *StreamingServer) waitForPlayback(ctx context.Context, folder string, path string, deliveredOffset int64) {
+	for srv.MaxBytesBufferedAhead > 0 {
+		position, ok := srv.playbackPosition(folder, path)
+		if !ok || deliveredOffset-position <= srv.MaxBytesBufferedAhead {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
 }
 
 func ceilDiv(a int64, b int64) int64 {
 	return (a + (b - 1)) / b
 }
 
+// Returns the number of bytes to keep cached to be able to read ahead for the specified duration while playing back at
+// the specified bitrate. As the puller fetches whole blocks, this is rounded up to a whole number of blocks, plus one for
+// the block that is currently being played (which is generally only partially consumed). Never more than the file.
+func recommendedCacheBytes(fileSize int64, blockSize int64, readAheadSeconds float64, bitrateBytesPerSec int64) int64 {
+	if fileSize <= 0 || blockSize <= 0 {
+		return 0
+	}
+
+	readAheadBytes := int64(math.Ceil(max(0, readAheadSeconds) * float64(max(0, bitrateBytesPerSec))))
+	blocks := ceilDiv(readAheadBytes, blockSize) + 1
+	return min(blocks, ceilDiv(fileSize, blockSize)) * blockSize
+}
+
 const (
 	signatureQueryParameter string = "signature"
+	rateQueryParameter      string = "rate"
 )
 
 func (srv *StreamingServer) port() int {
@@ -50,6 +161,21 @@ func (srv *StreamingServer) port() int {
 }
 
 func (srv *StreamingServer) urlFor(folder string, path string) string {
+	return srv.fileURL(folder, path, false, -1)
+}
+
+func (srv *StreamingServer) transcodingURLFor(folder string, path string) string {
+	return srv.fileURL(folder, path, true, -1)
+}
+
+// Returns a signed URL for the file that is streamed at most at the specified rate instead of MaxMbitsPerSecondsStreaming.
+// When maxMbits is zero, the file is streamed without limit.
+func (srv *StreamingServer) URLForWithRate(folder string, path string, maxMbits int64) string {
+	return srv.fileURL(folder, path, false, max(0, maxMbits))
+}
+
+// When maxMbits is negative, the stream is throttled according to MaxMbitsPerSecondsStreaming
+func (srv *StreamingServer) fileURL(folder string, path string, transcode bool, maxMbits int64) string {
 	url := url.URL{
 		Scheme: "http",
 		Host:   fmt.Sprintf("localhost:%d", srv.port()),
@@ -59,11 +185,26 @@ func (srv *StreamingServer) urlFor(folder string, path string) string {
 	q := url.Query()
 	q.Set("path", path)
 	q.Set("folder", folder)
+	if transcode {
+		q.Set("transcode", "1")
+	}
+	if maxMbits >= 0 {
+		q.Set(rateQueryParameter, strconv.FormatInt(maxMbits, 10))
+	}
 	url.RawQuery = q.Encode()
 	srv.signURL(&url)
 	return url.String()
 }
 
+// Returns the maximum streaming rate for a request: the rate in the (signed) URL when present, otherwise the server-wide
+// default. Zero means no limit.
+func (srv *StreamingServer) maxMbitsForRequest(query url.Values) int64 {
+	if rate, err := strconv.ParseInt(query.Get(rateQueryParameter), 10, 64); err == nil && rate >= 0 {
+		return rate
+	}
+	return srv.MaxMbitsPerSecondsStreaming
+}
+
 func (srv *StreamingServer) signURL(u *url.URL) {
 	// Remove any existing signature
 	qs := u.Query()
@@ -94,6 +235,51 @@ func (srv *StreamingServer) verifyURL(u *url.URL) bool {
 	return ed25519.Verify(srv.publicKey, []byte(partToVerify), signature)
 }
 
+// File in the config directory holding the URL signing key, when Client.PersistStreamingKey is set
+const streamingKeyFileName = "streaming-key.pem"
+
+// Loads the key used to sign URLs from the PEM file at path, or generates a key and saves it there when the file does not
+// exist yet
+func loadOrGenerateSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("signing key file is not valid PEM")
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("signing key is not an ed25519 key")
+		}
+		return privateKey, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+// Replaces the key used to sign and verify URLs. URLs signed with the previous key are no longer accepted.
+func (srv *StreamingServer) setSigningKey(privateKey ed25519.PrivateKey) {
+	srv.privateKey = privateKey
+	srv.publicKey = privateKey.Public().(ed25519.PublicKey)
+}
+
 func (srv *StreamingServer) Listen() error {
 	// Close existing listener
 	if srv.listener != nil {
@@ -125,6 +311,8 @@ func NewServer(app *syncthing.App, measurements *Measurements, ctx context.Conte
 		publicKey:                   publicKey,
 		privateKey:                  privateKey,
 		MaxMbitsPerSecondsStreaming: 0, // no limit
+		MaxBytesBufferedAhead:       0, // no limit
+		playbackPositions:           make(map[string]int64),
 	}
 
 	mux.Handle("/file", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -137,6 +325,14 @@ func NewServer(app *syncthing.App, measurements *Measurements, ctx context.Conte
 		folder := r.URL.Query().Get("folder")
 		path := r.URL.Query().Get("path")
 
+		release, err := server.acquireRequestSlot(r.Context())
+		if err != nil {
+			slog.Info("request abandoned while waiting for a slot", "method", r.Method, "folder", folder, "path", path)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+
 		slog.Info("request", "method", r.Method, "folder", folder, "path", path)
 		stFolder := server.client.FolderWithID(folder)
 		if stFolder == nil {
@@ -176,8 +372,9 @@ func NewServer(app *syncthing.App, measurements *Measurements, ctx context.Conte
 
 		startTime := time.Now()
 		var totalBytesSent int64 = 0
+		maxMbits := server.maxMbitsForRequest(r.URL.Query())
 
-		callback := func(bytesSent int64, bytesRequested int64) {
+		callback := func(deliveredOffset int64, bytesSent int64, bytesRequested int64) {
 			if server.Delegate != nil {
 				go server.Delegate.OnStreamChunk(folder, path, int64(bytesSent), bytesRequested)
 			}
@@ -185,18 +382,40 @@ func NewServer(app *syncthing.App, measurements *Measurements, ctx context.Conte
 
 			// Throttle the stream to a specific average Mbit/s to prevent streaming video from being donwloaded
 			// too quickly, wasting precious mobile data
-			if server.MaxMbitsPerSecondsStreaming > 0 {
+			if maxMbits > 0 {
 				blockFetchDurationMs := time.Since(startTime).Milliseconds()
-				blockFetchShouldHaveTakenMs := totalBytesSent * 8 / server.MaxMbitsPerSecondsStreaming / 1000
+				blockFetchShouldHaveTakenMs := totalBytesSent * 8 / maxMbits / 1000
 
 				if blockFetchDurationMs < blockFetchShouldHaveTakenMs {
 					time.Sleep(time.Duration(blockFetchShouldHaveTakenMs-blockFetchDurationMs) * time.Millisecond)
 				}
 			}
+
+			// Do not fetch further ahead of the player than necessary
+			server.waitForPlayback(r.Context(), folder, path, deliveredOffset)
+		}
+
+		onStalled := func(stalled bool) {
+			if server.Delegate != nil {
+				server.Delegate.OnStreamStalled(folder, path, stalled)
+			}
+		}
+
+		// Send transcoded contents when requested and necessary
+		if r.URL.Query().Get("transcode") != "" {
+			if targetMIMEType := server.transcodeTargetFor(stEntry); targetMIMEType != "" {
+				slog.Info("transcoding", "folder", folder, "path", path, "from", mime, "to", targetMIMEType)
+				server.client.recordAccess(folder, path)
+				mp := newMiniPuller(measurements, m)
+				mp.onStalled = onStalled
+				readSeeker := newEntryReadSeeker(info, mp, stEntry, r.Context(), callback)
+				serveTranscoded(w, r, server.Transcoder, readSeeker, info.Size, stEntry.MIMEType(), targetMIMEType)
+				return
+			}
 		}
 
 		// Send file contents to the client
-		serveEntry(w, r, folder, stEntry, info, m, measurements, callback)
+		serveEntry(w, r, folder, stEntry, info, m, measurements, callback, onStalled)
 	}))
 
 	if err := server.Listen(); err != nil {
@@ -207,40 +426,47 @@ func NewServer(app *syncthing.App, measurements *Measurements, ctx context.Conte
 }
 
 type entryReadSeeker struct {
-	info     protocol.FileInfo
-	offset   int64
-	puller   *miniPuller
-	entry    *Entry
-	context  context.Context
-	callback serveCallback
+	info       protocol.FileInfo
+	offset     int64
+	puller     *miniPuller
+	folderID   string
+	fetchLocal func(start int64, length int64) ([]byte, error)
+	context    context.Context
+	callback   serveCallback
 }
 
 func newEntryReadSeeker(info protocol.FileInfo, puller *miniPuller, entry *Entry, context context.Context, callback serveCallback) *entryReadSeeker {
 	return &entryReadSeeker{
-		info:     info,
-		offset:   0,
-		puller:   puller,
-		entry:    entry,
-		context:  context,
-		callback: callback,
+		info:       info,
+		offset:     0,
+		puller:     puller,
+		folderID:   entry.Folder.FolderID,
+		fetchLocal: entry.FetchLocal,
+		context:    context,
+		callback:   callback,
 	}
 }
 
-// Seek implements io.Seeker.
+// Seek implements io.Seeker. Seeking beyond the end is allowed (subsequent reads return io.EOF), but seeking to a
+// position before the start of the file is an error.
 func (e *entryReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var position int64
 	switch whence {
 	case io.SeekCurrent:
-		e.offset += offset
-		return e.offset, nil
+		position = e.offset + offset
 	case io.SeekStart:
-		e.offset = offset
-		return e.offset, nil
+		position = offset
 	case io.SeekEnd:
-		e.offset = offset + e.info.Size
-		return e.offset, nil
+		position = e.info.Size + offset
 	default:
 		return e.offset, errors.New("unsuported whence value")
 	}
+
+	if position < 0 {
+		return e.offset, errors.New("seek before start of file")
+	}
+	e.offset = position
+	return e.offset, nil
 }
 
 // Read implements io.Reader.
@@ -263,7 +489,7 @@ func (e *entryReadSeeker) Read(p []byte) (n int, err error) {
 	}
 
 	// Try to fulfill request locally
-	if bytes, err := e.entry.FetchLocal(e.offset, size); err == nil && bytes != nil {
+	if bytes, err := e.fetchLocal(e.offset, size); err == nil && bytes != nil {
 		total := copy(p, bytes)
 		e.offset += int64(total)
 		return total, nil
@@ -272,22 +498,26 @@ func (e *entryReadSeeker) Read(p []byte) (n int, err error) {
 	// Start pulling those blocks
 	blockSize := int64(e.info.BlockSize())
 	startBlock := e.offset / int64(blockSize)
-	blockCount := ceilDiv(int64(size), blockSize)
 
-	// If we start halfway the first block, we need to fetch another one at the end to make up for it
+	// If we start halfway the first block, the range may extend into one more block at the end. Only fetch the blocks
+	// that the range actually overlaps, so small range requests do not pull in blocks that are not needed.
 	offsetInStartBlock := e.offset % int64(blockSize)
-	if offsetInStartBlock > 0 {
-		blockCount += 1
-	}
+	blockCount := ceilDiv(offsetInStartBlock+size, blockSize)
 
 	var bytesRead int64 = 0
-	folderID := e.entry.Folder.FolderID
+	folderID := e.folderID
 
 	for blockIndex := startBlock; blockIndex < startBlock+blockCount; blockIndex++ {
 		if int(blockIndex) > len(e.info.Blocks)-1 {
 			break
 		}
 
+		// Stop pulling blocks as soon as the client has gone away (e.g. the player was closed)
+		if err := e.context.Err(); err != nil {
+			e.offset += bytesRead
+			return int(bytesRead), err
+		}
+
 		// Fetch block
 		block := e.info.Blocks[blockIndex]
 		buf, err := e.puller.downloadBlock(e.context, folderID, int(blockIndex), e.info)
@@ -319,7 +549,7 @@ func (e *entryReadSeeker) Read(p []byte) (n int, err error) {
 		copy(p[bytesRead:], buf[bufStart:bufEnd])
 		bytesRead += (bufEnd - bufStart)
 		if e.callback != nil {
-			e.callback(bytesRead, size)
+			e.callback(e.offset+bytesRead, bytesRead, size)
 		}
 	}
 
@@ -329,20 +559,54 @@ func (e *entryReadSeeker) Read(p []byte) (n int, err error) {
 
 var _ io.ReadSeeker = &entryReadSeeker{}
 
-type serveCallback func(bytesSent int64, bytesRequested int64)
-
-func serveEntry(w http.ResponseWriter, r *http.Request, folderID string, entry *Entry, info protocol.FileInfo, m *syncthing.Internals, measurements *Measurements, callback serveCallback) {
-	// Disable caching
-	w.Header().Add("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Header().Add("Pragma", "no-cache")
-	w.Header().Add("Expires", "0")
+type serveCallback func(deliveredOffset int64, bytesSent int64, bytesRequested int64)
 
+// Serves the contents of the entry, fetching blocks from peers as needed. When set, onStalled is called when fetching a
+// block stalls (see miniPuller.onStalled).
+func serveEntry(w http.ResponseWriter, r *http.Request, folderID string, entry *Entry, info protocol.FileInfo, m *syncthing.Internals, measurements *Measurements, callback serveCallback, onStalled func(stalled bool)) {
 	if entry.Size() == 0 {
+		setNoCacheHeaders(w)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	// Encrypted contents should be saved, not rendered
+	if entry.IsEncrypted() {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+
+	entry.Folder.client.recordAccess(folderID, entry.Path())
+
 	mp := newMiniPuller(measurements, m)
+	mp.onStalled = onStalled
 	readSeeker := newEntryReadSeeker(info, mp, entry, r.Context(), callback)
-	http.ServeContent(w, r, entry.info.Name, entry.info.ModTime(), readSeeker)
+	serveContent(w, r, entry.info.Name, entry.info.ModTime(), entryETag(info), readSeeker)
+}
+
+// Returns a strong ETag for the contents of a file, or an empty string if the file has no blocks hash
+func entryETag(info protocol.FileInfo) string {
+	if len(info.BlocksHash) == 0 {
+		return ""
+	}
+	return `"` + hex.EncodeToString(info.BlocksHash) + `"`
+}
+
+func setNoCacheHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+}
+
+// Serves (ranges of) content. When an ETag is provided, clients may cache the response and revalidate it using
+// conditional requests (If-None-Match, If-Range), which are handled by http.ServeContent. Without an ETag caching is
+// disabled, as the contents may change without the modification time changing.
+func serveContent(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, etag string, content io.ReadSeeker) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		setNoCacheHeaders(w)
+	}
+	http.ServeContent(w, r, name, modTime, content)
 }
@@ -0,0 +1,337 @@
+package sushitrain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Creates a read seeker for a file whose blocks are all present in the block cache, so no peers are needed
+func newCachedEntryReadSeeker(t *testing.T, data []byte) (*entryReadSeeker, protocol.FileInfo) {
+	t.Helper()
+	blockSize := protocol.MinBlockSize
+	info := protocol.FileInfo{Name: "video.mp4", Size: int64(len(data)), RawBlockSize: int32(blockSize)}
+	for offset := 0; offset < len(data); offset += blockSize {
+		end := min(offset+blockSize, len(data))
+		hash := sha256.Sum256(data[offset:end])
+		info.Blocks = append(info.Blocks, protocol.BlockInfo{Offset: int64(offset), Size: end - offset, Hash: hash[:]})
+		blockCache.Add(base64.StdEncoding.EncodeToString(hash[:]), data[offset:end])
+	}
+	blocksHash := sha256.Sum256(data)
+	info.BlocksHash = blocksHash[:]
+
+	return &entryReadSeeker{
+		info:     info,
+		puller:   &miniPuller{},
+		folderID: "test",
+		fetchLocal: func(start int64, length int64) ([]byte, error) {
+			return nil, errors.New("file not available")
+		},
+		context: context.Background(),
+	}, info
+}
+
+func TestServeContentRanges(t *testing.T) {
+	t.Cleanup(ClearBlockCache)
+	data := make([]byte, protocol.MinBlockSize*3+1234)
+	rand.New(rand.NewSource(1)).Read(data)
+	readSeeker, info := newCachedEntryReadSeeker(t, data)
+	etag := entryETag(info)
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	blockSize := int64(protocol.MinBlockSize)
+	ranges := [][2]int64{
+		{0, 9},
+		{5, 20},
+		{blockSize - 10, blockSize + 10},
+		{blockSize - 5, blockSize*2 + 5},
+		{blockSize * 3, int64(len(data)) - 1},
+		{100, 100},
+	}
+
+	for _, rng := range ranges {
+		req := httptest.NewRequest("GET", "/file", nil)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", rng[0], rng[1]))
+		rec := httptest.NewRecorder()
+		serveContent(rec, req, info.Name, modTime, etag, readSeeker)
+
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("range %v: unexpected status %d", rng, rec.Code)
+		}
+		expectedRange := fmt.Sprintf("bytes %d-%d/%d", rng[0], rng[1], len(data))
+		if cr := rec.Header().Get("Content-Range"); cr != expectedRange {
+			t.Errorf("range %v: unexpected Content-Range %q (expected %q)", rng, cr, expectedRange)
+		}
+		if rec.Header().Get("ETag") != etag {
+			t.Errorf("range %v: unexpected ETag %q", rng, rec.Header().Get("ETag"))
+		}
+		if !bytes.Equal(rec.Body.Bytes(), data[rng[0]:rng[1]+1]) {
+			t.Errorf("range %v: returned bytes do not match file contents", rng)
+		}
+	}
+}
+
+func TestServeContentConditional(t *testing.T) {
+	t.Cleanup(ClearBlockCache)
+	data := make([]byte, protocol.MinBlockSize+10)
+	rand.New(rand.NewSource(2)).Read(data)
+	readSeeker, info := newCachedEntryReadSeeker(t, data)
+	etag := entryETag(info)
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec := httptest.NewRecorder()
+	serveContent(rec, req, info.Name, modTime, etag, readSeeker)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("unexpected status for matching If-None-Match: %d", rec.Code)
+	}
+
+	// A range request with a stale If-Range should return the full file
+	req = httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("If-Range", `"stale"`)
+	rec = httptest.NewRecorder()
+	serveContent(rec, req, info.Name, modTime, etag, readSeeker)
+	if rec.Code != http.StatusOK {
+		t.Errorf("unexpected status for stale If-Range: %d", rec.Code)
+	}
+	if body, _ := io.ReadAll(rec.Body); !bytes.Equal(body, data) {
+		t.Errorf("full response does not match file contents")
+	}
+}
+
+func TestRecommendedCacheBytes(t *testing.T) {
+	blockSize := int64(protocol.MinBlockSize)
+	cases := []struct {
+		fileSize         int64
+		readAheadSeconds float64
+		bitrate          int64
+		expected         int64
+	}{
+		{0, 10, 1000, 0},
+		{blockSize * 100, 0, 1000, blockSize},
+		{blockSize * 100, 1, blockSize, blockSize * 2},
+		{blockSize * 100, 2.5, blockSize, blockSize * 4},
+		{blockSize * 100, 10, -5, blockSize},
+		{blockSize * 3, 60, blockSize, blockSize * 3},
+		{blockSize*3 + 1, 60, blockSize, blockSize * 4},
+	}
+
+	for _, c := range cases {
+		if actual := recommendedCacheBytes(c.fileSize, blockSize, c.readAheadSeconds, c.bitrate); actual != c.expected {
+			t.Errorf("recommendedCacheBytes(%d, %f, %d) = %d, expected %d", c.fileSize, c.readAheadSeconds, c.bitrate, actual, c.expected)
+		}
+	}
+}
+
+type testTranscoder struct{}
+
+func (testTranscoder) TargetMIMEType(sourceMIMEType string) string {
+	return "audio/aac"
+}
+
+// Copies the source in small chunks, reversing the bytes of each chunk
+func (testTranscoder) Transcode(source *TranscodeSource, sourceMIMEType string, targetMIMEType string, output *TranscodeOutput) error {
+	for offset := int64(0); offset < source.Size(); offset += 1000 {
+		chunk, err := source.ReadAt(offset, 1000)
+		if err != nil {
+			return err
+		}
+		slices.Reverse(chunk)
+		if err := output.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestServeTranscoded(t *testing.T) {
+	t.Cleanup(ClearBlockCache)
+	data := make([]byte, protocol.MinBlockSize*2+567)
+	rand.New(rand.NewSource(2)).Read(data)
+	readSeeker, info := newCachedEntryReadSeeker(t, data)
+
+	req := httptest.NewRequest("GET", "/file?transcode=1", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	rec := httptest.NewRecorder()
+	serveTranscoded(rec, req, testTranscoder{}, readSeeker, info.Size, "audio/flac", "audio/aac")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "audio/aac" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	expected := make([]byte, 0, len(data))
+	for offset := 0; offset < len(data); offset += 1000 {
+		chunk := slices.Clone(data[offset:min(offset+1000, len(data))])
+		slices.Reverse(chunk)
+		expected = append(expected, chunk...)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), expected) {
+		t.Error("transcoded output does not match")
+	}
+}
+
+func TestEntryReadSeekerSeekEnd(t *testing.T) {
+	t.Cleanup(ClearBlockCache)
+	data := make([]byte, protocol.MinBlockSize+100)
+	rand.New(rand.NewSource(3)).Read(data)
+	readSeeker, info := newCachedEntryReadSeeker(t, data)
+
+	if size, err := readSeeker.Seek(0, io.SeekEnd); err != nil || size != info.Size {
+		t.Fatalf("seek to end returned (%d, %v), expected size %d", size, err, info.Size)
+	}
+
+	pos, err := readSeeker.Seek(-10, io.SeekEnd)
+	if err != nil || pos != info.Size-10 {
+		t.Fatalf("seek to 10 bytes before end returned (%d, %v)", pos, err)
+	}
+	tail, err := io.ReadAll(readSeeker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tail, data[len(data)-10:]) {
+		t.Error("bytes read after seeking from end do not match")
+	}
+
+	if _, err := readSeeker.Seek(-info.Size-1, io.SeekEnd); err == nil {
+		t.Error("expected an error when seeking before the start")
+	}
+	if pos, _ := readSeeker.Seek(0, io.SeekCurrent); pos != info.Size {
+		t.Errorf("failed seek should not move the offset, now at %d", pos)
+	}
+}
+
+func TestServeContentHead(t *testing.T) {
+	t.Cleanup(ClearBlockCache)
+	data := make([]byte, protocol.MinBlockSize*2+77)
+	rand.New(rand.NewSource(4)).Read(data)
+	readSeeker, info := newCachedEntryReadSeeker(t, data)
+
+	req := httptest.NewRequest("HEAD", "/file", nil)
+	rec := httptest.NewRecorder()
+	serveContent(rec, req, info.Name, time.Now(), entryETag(info), readSeeker)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != fmt.Sprintf("%d", info.Size) {
+		t.Errorf("unexpected Content-Length %q, expected %d", cl, info.Size)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("HEAD response should not have a body, got %d bytes", rec.Body.Len())
+	}
+}
+
+func TestPersistedSigningKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), streamingKeyFileName)
+	firstKey, err := loadOrGenerateSigningKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := os.Stat(keyPath); err != nil || stat.Mode().Perm() != 0o600 {
+		t.Fatalf("expected key file to be private, got %v (%v)", stat, err)
+	}
+
+	// A server that loads the same key after a restart accepts previously signed URLs
+	signing := &StreamingServer{}
+	signing.setSigningKey(firstKey)
+	u := &url.URL{Path: "/file", RawQuery: "folder=a&path=b.mp4"}
+	signing.signURL(u)
+
+	secondKey, err := loadOrGenerateSigningKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifying := &StreamingServer{}
+	verifying.setSigningKey(secondKey)
+	if !verifying.verifyURL(u) {
+		t.Error("expected URL signed before restart to be accepted")
+	}
+}
+
+func TestMaxMbitsForRequest(t *testing.T) {
+	srv := &StreamingServer{MaxMbitsPerSecondsStreaming: 4}
+	cases := map[string]int64{
+		"":        4,
+		"rate=0":  0,
+		"rate=10": 10,
+		"rate=-3": 4,
+		"rate=x":  4,
+	}
+	for rawQuery, expected := range cases {
+		query, _ := url.ParseQuery(rawQuery)
+		if got := srv.maxMbitsForRequest(query); got != expected {
+			t.Errorf("%q: expected %d, got %d", rawQuery, expected, got)
+		}
+	}
+
+	// The rate is covered by the signature
+	key, err := loadOrGenerateSigningKey(filepath.Join(t.TempDir(), streamingKeyFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.setSigningKey(key)
+	u := &url.URL{Path: "/file", RawQuery: "folder=a&path=b.mp4&rate=1"}
+	srv.signURL(u)
+	tampered := *u
+	tampered.RawQuery = strings.Replace(u.RawQuery, "rate=1", "rate=0", 1)
+	if !srv.verifyURL(u) || srv.verifyURL(&tampered) {
+		t.Error("expected changing the rate to invalidate the signature")
+	}
+}
+
+func TestEntryReadSeekerStopsWhenCancelled(t *testing.T) {
+	t.Cleanup(ClearBlockCache)
+	data := make([]byte, protocol.MinBlockSize*3)
+	rand.New(rand.NewSource(1)).Read(data)
+	readSeeker, _ := newCachedEntryReadSeeker(t, data)
+
+	// Cancel the request after the first block has been delivered
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	readSeeker.context = ctx
+	readSeeker.callback = func(deliveredOffset int64, bytesSent int64, bytesRequested int64) {
+		cancel()
+	}
+
+	hitsBefore, missesBefore := blockCacheHits.Load(), blockCacheMisses.Load()
+	buf := make([]byte, len(data))
+	n, err := readSeeker.Read(buf)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if n != protocol.MinBlockSize || !bytes.Equal(buf[:n], data[:n]) {
+		t.Errorf("expected exactly the first block, got %d bytes", n)
+	}
+	if hits, misses := blockCacheHits.Load()-hitsBefore, blockCacheMisses.Load()-missesBefore; hits != 1 || misses != 0 {
+		t.Errorf("expected a single block to be fetched, got %d hits and %d misses", hits, misses)
+	}
+
+	// Subsequent reads should not fetch any blocks either
+	if _, err := readSeeker.Read(buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error on subsequent read, got %v", err)
+	}
+	if hits := blockCacheHits.Load() - hitsBefore; hits != 1 {
+		t.Errorf("no blocks should be fetched after cancellation, got %d", hits)
+	}
+}
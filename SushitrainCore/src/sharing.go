@@ -0,0 +1,128 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const sharingPayloadScheme = "syncthing"
+
+// Builds a sharing payload of the form syncthing:<device ID>?name=<name>&addr=<address>&addr=...
+func sharingPayload(deviceID protocol.DeviceID, name string, addresses []string) string {
+	q := url.Values{}
+	if name != "" {
+		q.Set("name", name)
+	}
+	for _, addr := range addresses {
+		q.Add("addr", addr)
+	}
+
+	u := url.URL{Scheme: sharingPayloadScheme, Opaque: deviceID.String(), RawQuery: q.Encode()}
+	return u.String()
+}
+
+type parsedSharingPayload struct {
+	deviceID  protocol.DeviceID
+	name      string
+	addresses []string
+}
+
+func parseSharingDeviceID(deviceID string) (protocol.DeviceID, error) {
+	if deviceID == "" {
+		return protocol.EmptyDeviceID, errors.New("sharing payload does not contain a device ID")
+	}
+	return protocol.DeviceIDFromString(deviceID)
+}
+
+// Parses a payload produced by sharingPayload, or a bare device ID (as shared by other Syncthing apps). Addresses that
+// are not valid are left out.
+func parseSharingPayload(payload string) (*parsedSharingPayload, error) {
+	payload = strings.TrimSpace(payload)
+	if !strings.HasPrefix(strings.ToLower(payload), sharingPayloadScheme+":") {
+		deviceID, err := parseSharingDeviceID(payload)
+		if err != nil {
+			return nil, err
+		}
+		return &parsedSharingPayload{deviceID: deviceID, addresses: []string{}}, nil
+	}
+
+	u, err := url.Parse(payload)
+	if err != nil {
+		return nil, err
+	}
+	deviceID, err := parseSharingDeviceID(u.Opaque)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	addresses := Filter(q["addr"], func(addr string) bool {
+		addrURL, err := url.Parse(addr)
+		return err == nil && addrURL.Scheme != "" && addrURL.Host != ""
+	})
+	return &parsedSharingPayload{deviceID: deviceID, name: q.Get("name"), addresses: addresses}, nil
+}
+
+// Returns the addresses at which this device can currently be reached directly (relay addresses are left out)
+func (clt *Client) directAddresses() []string {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	addresses := make([]string, 0)
+	for _, resolved := range clt.ResolvedListenAddresses {
+		for _, addr := range resolved {
+			if !strings.HasPrefix(addr, "relay://") && !slices.Contains(addresses, addr) {
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+	slices.Sort(addresses)
+	return addresses
+}
+
+/*
+* Returns a compact URL describing this device (its ID, name and the addresses at which it can currently be reached
+directly) for sharing as a QR code. It can be read with ParseSharingPayload.
+*/
+func (clt *Client) SharingPayload() (string, error) {
+	if clt.config == nil {
+		return "", ErrStillLoading
+	}
+
+	name, err := clt.GetName()
+	if err != nil {
+		return "", err
+	}
+	return sharingPayload(clt.deviceID(), name, clt.directAddresses()), nil
+}
+
+/*
+* Reads a payload created by SharingPayload (or a bare device ID) and returns the described peer, which is not added.
+When the peer has not been added yet, its Name and Addresses are those suggested by the payload. Addresses should be
+used in addition to "dynamic", as the suggested addresses may only be valid in a particular network.
+*/
+func (clt *Client) ParseSharingPayload(payload string) (*Peer, error) {
+	parsed, err := parseSharingPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	if clt.cert != nil && parsed.deviceID == clt.deviceID() {
+		return nil, errors.New("sharing payload describes this device")
+	}
+
+	return &Peer{
+		client:             clt,
+		deviceID:           parsed.deviceID,
+		suggestedName:      parsed.name,
+		suggestedAddresses: parsed.addresses,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package sushitrain
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSharingPayloadRoundTrip(t *testing.T) {
+	deviceID := protocol.DeviceID{1, 2, 3}
+	payload := sharingPayload(deviceID, "My iPhone & iPad", []string{"tcp://192.168.1.5:22000", "quic://[fe80::1]:22000"})
+
+	parsed, err := parseSharingPayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.deviceID != deviceID || parsed.name != "My iPhone & iPad" {
+		t.Errorf("unexpected parse result: %+v", parsed)
+	}
+	if !slices.Equal(parsed.addresses, []string{"tcp://192.168.1.5:22000", "quic://[fe80::1]:22000"}) {
+		t.Errorf("unexpected addresses: %v", parsed.addresses)
+	}
+}
+
+func TestParseSharingPayload(t *testing.T) {
+	deviceID := protocol.DeviceID{4, 5, 6}
+
+	// Bare device IDs as shared by other apps
+	parsed, err := parseSharingPayload(" " + deviceID.String() + "\n")
+	if err != nil || parsed.deviceID != deviceID || len(parsed.addresses) != 0 {
+		t.Errorf("unexpected result for bare device ID: %+v (%v)", parsed, err)
+	}
+
+	// Invalid addresses are left out
+	parsed, err = parseSharingPayload("syncthing:" + deviceID.String() + "?addr=nonsense&addr=tcp://10.0.0.1:22000")
+	if err != nil || !slices.Equal(parsed.addresses, []string{"tcp://10.0.0.1:22000"}) {
+		t.Errorf("unexpected result with invalid address: %+v (%v)", parsed, err)
+	}
+
+	for _, invalid := range []string{"", "syncthing:", "syncthing:NOTADEVICE", "hello"} {
+		if _, err := parseSharingPayload(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -17,10 +17,13 @@ import (
 	"io"
 	"iter"
 	"log/slog"
+	"maps"
 	"math"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"runtime/pprof"
 	"slices"
@@ -55,16 +58,54 @@ type Client struct {
 	IsUsingCustomConfiguration bool
 	Server                     *StreamingServer
 
+	// When set before calling Start, the key used to sign streaming URLs is kept across restarts, so that URLs handed
+	// out earlier (e.g. cached by a web view) remain valid. Note that URLs also contain the port the streaming server
+	// listens on, which may change between restarts.
+	PersistStreamingKey bool
+
+	// When set before calling Start, folders report scan progress (see Folder.ScanProgress). This is off by default, as
+	// progress reports lead to some extra memory usage and CPU time while scanning.
+	ReportScanProgress bool
+
 	connectedDeviceAddresses map[string]string
+	connectedDeviceTypes     map[string]string                           // deviceID => connection type, e.g. "tcp-client" or "relay-server"
+	connectedDeviceSince     map[string]time.Time                        // deviceID => time at which the current connection was established
+	discoveredAddresses      map[string][]string                         // deviceID => addresses last found through discovery
 	downloadProgress         map[string]map[string]*model.PullerProgress // folderID, path => progress
 	uploadProgress           map[string]map[string]map[string]int        // deviceID, folderID, path => block count
 	foldersDownloading       map[string]bool
 	ResolvedListenAddresses  map[string][]string
 	mutex                    sync.Mutex
-	extraneousIgnored        []string
+	configChangeMutex        sync.Mutex // Serializes changeConfiguration
+	extraneousIgnored        []string   // Set through SetExtraneousIgnored (copy on write)
+	extraneousIgnorePatterns []string   // Added through AddExtraneousIgnorePattern (copy on write)
 	Measurements             *Measurements
 	logHandler               *logHandler
+	recentChanges            *changeTail
 	appLock                  *flock.Flock
+	exportDirectory          string
+	startedAt                time.Time
+	autoAcceptFolderPaths    map[string]string // deviceID => path template
+	autoAcceptMutex          sync.Mutex
+
+	downloadRateSampler        transferRateSampler
+	uploadRateSampler          transferRateSampler
+	folderDownloadRateSamplers map[string]*transferRateSampler
+	scanProgress               map[string]*ScanProgress // folderID => latest scan progress
+	bandwidthSchedule          []BandwidthScheduleEntry
+	appliedScheduleEntry       int                // Index of the schedule entry last applied, -1 for none, -2 when not yet applied
+	reportedCompletions        map[string]float64 // folderID + "\x00" + deviceID => completion percentage last reported
+	onCellular                 bool
+	cellularPolicy             cellularPolicy
+	conflictPolicies           map[string]string            // folderID => conflict policy (see Folder.SetConflictPolicy)
+	pendingConflicts           map[string]map[string]bool   // folderID => original paths of conflicts awaiting automatic resolution
+	conflictResolutionMutex    sync.Mutex                   // Serializes automatic conflict resolution
+	timedPauses                map[string]time.Time         // folderID => time at which the folder should be resumed
+	timedPauseTimers           map[string]*time.Timer       // folderID => timer that resumes the folder
+	recentlyAccessed           []AccessRecord               // Most recent first, loaded on first use
+	recentlyAccessedSaveTimer  *time.Timer                  // Pending save of recentlyAccessed, nil when none
+	recentlyAccessedSaveMutex  sync.Mutex                   // Serializes writing and removing the recently accessed file
+	folderErrors               map[string][]model.FileError // folderID => items that failed in the last pull
 }
 
 type Change struct {
@@ -95,10 +136,32 @@ type ClientDelegate interface {
 	OnListenAddressesChanged(addresses *ListOfStrings)
 	OnChange(change *Change)
 	OnMeasurementsUpdated()
+	// Called when the completion percentage (0...100) of a folder on a remote device changes meaningfully
+	OnFolderCompletionChanged(folderID string, deviceID string, pct float64)
 }
 
+// Minimum change in completion percentage before the delegate is informed
+const folderCompletionReportThreshold = 0.5
+
 var (
 	ErrStillLoading = errors.New("still loading")
+
+	// Errors returned (wrapped) by Client.Load and Client.Start. Use StartErrorKind to classify.
+	ErrCertificateUnreadable = errors.New("certificate could not be loaded")
+	ErrConfigUnreadable      = errors.New("configuration could not be loaded")
+	ErrAlreadyRunning        = errors.New("the app cannot be started, as it appears it is already running")
+	ErrDatabaseCorrupt       = errors.New("database is corrupt")
+	ErrDatabaseUnavailable   = errors.New("database could not be opened")
+	ErrDiskFull              = errors.New("disk is full")
+	ErrServerUnavailable     = errors.New("streaming server could not be started")
+
+	// Returned (wrapped) when a configuration change could not be saved, e.g. because the disk is full. The change is
+	// then not applied.
+	ErrConfigSaveFailed = errors.New("configuration could not be saved")
+
+	ErrEncryptionPasswordTooShort = errors.New("encryption password is too short")
+	// Returned for passwords that are long enough but easy to guess. Callers may choose to only warn about these.
+	ErrEncryptionPasswordWeak = errors.New("encryption password is easy to guess")
 )
 
 const (
@@ -195,6 +258,9 @@ func NewClient(configPath string, filesPath string, saveLog bool) *Client {
 		Server:                     nil,
 		foldersDownloading:         make(map[string]bool, 0),
 		connectedDeviceAddresses:   make(map[string]string, 0),
+		connectedDeviceTypes:       make(map[string]string, 0),
+		connectedDeviceSince:       make(map[string]time.Time, 0),
+		discoveredAddresses:        make(map[string][]string, 0),
 		IsUsingCustomConfiguration: isUsingCustomConfiguration,
 		filesPath:                  filesPath,
 		IgnoreEvents:               false,
@@ -203,11 +269,20 @@ func NewClient(configPath string, filesPath string, saveLog bool) *Client {
 		extraneousIgnored:          make([]string, 0),
 		Measurements:               nil,
 		logHandler:                 logHandler,
+		recentChanges:              newChangeTail(maxRecentChanges),
+		folderErrors:               make(map[string][]model.FileError),
+		pendingConflicts:           make(map[string]map[string]bool),
+		timedPauses:                make(map[string]time.Time),
+		timedPauseTimers:           make(map[string]*time.Timer),
 	}
 }
 
+// Sets the names of files that are not considered extraneous. Patterns added using AddExtraneousIgnorePattern (or
+// ApplyDefaultJunkIgnores) are kept.
 func (clt *Client) SetExtraneousIgnored(names []string) {
-	clt.extraneousIgnored = names
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	clt.extraneousIgnored = slices.Clone(names)
 }
 
 func (clt *Client) SetExtraneousIgnoredJSON(js []byte) error {
@@ -226,8 +301,104 @@ func (clt *Client) isExtraneousIgnored(name string) bool {
 		return true
 	}
 
-	// Must be an equal match for now
-	return slices.Contains(clt.extraneousIgnored, name)
+	// Both lists are replaced rather than modified, so they can be used without holding the lock
+	clt.mutex.Lock()
+	names, patterns := clt.extraneousIgnored, clt.extraneousIgnorePatterns
+	clt.mutex.Unlock()
+
+	matches := func(pattern string) bool {
+		return matchesExtraneousIgnorePattern(pattern, name)
+	}
+	return slices.ContainsFunc(names, matches) || slices.ContainsFunc(patterns, matches)
+}
+
+// Entries without glob metacharacters must match the name exactly, other entries are matched using path.Match
+func matchesExtraneousIgnorePattern(pattern string, name string) bool {
+	if !strings.ContainsAny(pattern, "*?[\\") {
+		return pattern == name
+	}
+	matched, err := path.Match(pattern, name)
+	if err != nil {
+		// Not a valid pattern (e.g. set through SetExtraneousIgnored), so treat it as a plain name
+		return pattern == name
+	}
+	return matched
+}
+
+// Operating system junk files that are safe to ignore (and remove when cleaning up extraneous files)
+var defaultJunkIgnorePatterns = []string{
+	".DS_Store",
+	"._*", // AppleDouble files holding macOS metadata on non-Apple filesystems
+	"Icon\r",
+	"Thumbs.db",
+	"ehthumbs.db",
+	"desktop.ini",
+}
+
+// Adds a file name pattern (e.g. "*.tmp" or "Thumbs.db") to the list of names that are not considered extraneous (see
+// SetExtraneousIgnored). Patterns use the syntax of path.Match and are matched against the file name only. Note that
+// files matching these patterns are removed when their containing directory is cleaned up. Added patterns are kept when
+// SetExtraneousIgnored is called, until the client is stopped.
+func (clt *Client) AddExtraneousIgnorePattern(glob string) error {
+	if _, err := path.Match(glob, ""); err != nil {
+		return err
+	}
+
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	if !slices.Contains(clt.extraneousIgnorePatterns, glob) {
+		clt.extraneousIgnorePatterns = append(slices.Clone(clt.extraneousIgnorePatterns), glob)
+	}
+	return nil
+}
+
+// Adds patterns for common macOS and Windows junk files (such as .DS_Store and Thumbs.db) to the extraneous ignore list
+func (clt *Client) ApplyDefaultJunkIgnores() {
+	for _, pattern := range defaultJunkIgnorePatterns {
+		if err := clt.AddExtraneousIgnorePattern(pattern); err != nil {
+			slog.Warn("invalid default junk ignore pattern", "pattern", pattern, "cause", err)
+		}
+	}
+}
+
+// The directory where downloads end up when no explicit destination is given (e.g. Entry.Download with empty path)
+func (clt *Client) DefaultExportDirectory() string {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	if clt.exportDirectory == "" {
+		return os.TempDir()
+	}
+	return clt.exportDirectory
+}
+
+// Set to an empty string to revert to the default (temporary directory). Should be outside of any synced folder.
+func (clt *Client) SetDefaultExportDirectory(dir string) {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	clt.exportDirectory = dir
+}
+
+// The number of alternative names uniqueExportPath tries before giving up
+const maxUniqueExportPathAttempts = 1000
+
+// Returns a path in the export directory for a file with the given name that does not exist yet. When a file with the
+// same name exists, a counter is appended to the name (e.g. "file (1).txt").
+func uniqueExportPath(dir string, fileName string) (string, error) {
+	candidate := path.Join(dir, fileName)
+	ext := path.Ext(fileName)
+	base := strings.TrimSuffix(fileName, ext)
+
+	for counter := 1; counter <= maxUniqueExportPathAttempts; counter++ {
+		_, err := os.Lstat(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+		candidate = path.Join(dir, fmt.Sprintf("%s (%d)%s", base, counter, ext))
+	}
+	return "", fmt.Errorf("could not find an unused name for '%s' in the export directory", fileName)
 }
 
 func (clt *Client) CurrentConfigDirectory() string {
@@ -270,16 +441,26 @@ func (clt *Client) Stop() {
 	clt.app.Stop(svcutil.ExitSuccess)
 	clt.cancel()
 	clt.app.Wait()
+
+	// Write out recent accesses that have not been saved yet
+	clt.mutex.Lock()
+	pendingSave := clt.recentlyAccessedSaveTimer != nil && clt.recentlyAccessedSaveTimer.Stop()
+	clt.mutex.Unlock()
+	if pendingSave {
+		clt.saveRecentlyAccessed()
+	}
 }
 
 func (clt *Client) handleEvent(evt events.Event) {
 	switch evt.Type {
 	case events.DeviceDiscovered:
+		data := evt.Data.(map[string]interface{})
+		devID := data["device"].(string)
+		addresses := data["addrs"].([]string)
+
 		clt.mutex.Lock()
+		clt.discoveredAddresses[devID] = addresses
 		if !clt.IgnoreEvents && clt.Delegate != nil {
-			data := evt.Data.(map[string]interface{})
-			devID := data["device"].(string)
-			addresses := data["addrs"].([]string)
 			clt.mutex.Unlock()
 			clt.Delegate.OnDeviceDiscovered(devID, &ListOfStrings{data: addresses})
 		} else {
@@ -299,6 +480,12 @@ func (clt *Client) handleEvent(evt events.Event) {
 
 		clt.mutex.Lock()
 		clt.foldersDownloading[folder] = folderTransferring
+		if state != model.FolderScanning.String() {
+			delete(clt.scanProgress, folder)
+		}
+		if state == model.FolderIdle.String() && len(clt.pendingConflicts[folder]) > 0 {
+			go clt.resolvePendingConflicts(folder)
+		}
 		if !clt.IgnoreEvents && clt.Delegate != nil {
 			clt.mutex.Unlock()
 			clt.Delegate.OnEvent(evt.Type.String())
@@ -341,6 +528,11 @@ func (clt *Client) handleEvent(evt events.Event) {
 
 		clt.mutex.Lock()
 		clt.connectedDeviceAddresses[devID] = address
+		clt.connectedDeviceTypes[devID] = data["type"]
+		if _, isConnected := clt.connectedDeviceSince[devID]; !isConnected {
+			// Additional connections to an already connected device do not reset the connection time
+			clt.connectedDeviceSince[devID] = evt.Time
+		}
 
 		if !clt.IgnoreEvents && clt.Delegate != nil {
 			clt.mutex.Unlock()
@@ -356,22 +548,38 @@ func (clt *Client) handleEvent(evt events.Event) {
 			modifiedBy = clt.DeviceID()
 		}
 
+		change := &Change{
+			FolderID: data["folder"],
+			ShortID:  modifiedBy,
+			Action:   data["action"],
+			Path:     data["path"],
+			Time:     &Date{time: evt.Time},
+		}
+		clt.recentChanges.append(change)
+		clt.handleConflictPolicyChange(change.FolderID, change.Action, change.Path)
+
 		clt.mutex.Lock()
 		if !clt.IgnoreEvents && clt.Delegate != nil {
-			go clt.Delegate.OnChange(&Change{
-				FolderID: data["folder"],
-				ShortID:  modifiedBy,
-				Action:   data["action"],
-				Path:     data["path"],
-				Time:     &Date{time: evt.Time},
-			})
+			go clt.Delegate.OnChange(change)
+			clt.mutex.Unlock()
+			clt.Delegate.OnEvent(evt.Type.String())
+		} else {
+			clt.mutex.Unlock()
+		}
+
+	case events.DeviceDisconnected:
+		data := evt.Data.(map[string]string)
+
+		clt.mutex.Lock()
+		delete(clt.connectedDeviceSince, data["id"])
+		if !clt.IgnoreEvents && clt.Delegate != nil {
 			clt.mutex.Unlock()
 			clt.Delegate.OnEvent(evt.Type.String())
 		} else {
 			clt.mutex.Unlock()
 		}
 
-	case events.LocalIndexUpdated, events.DeviceDisconnected, events.ConfigSaved,
+	case events.LocalIndexUpdated, events.ConfigSaved,
 		events.ClusterConfigReceived, events.FolderResumed, events.FolderPaused:
 		// Just deliver the event
 		clt.mutex.Lock()
@@ -416,6 +624,63 @@ func (clt *Client) handleEvent(evt events.Event) {
 			clt.mutex.Unlock()
 		}
 
+	case events.PendingFoldersChanged:
+		go clt.autoAcceptPendingFolders()
+
+	case events.FolderCompletion:
+		data := evt.Data.(map[string]interface{})
+		folder := data["folder"].(string)
+		device := data["device"].(string)
+		pct := data["completion"].(float64)
+		key := folder + "\x00" + device
+
+		clt.mutex.Lock()
+		if clt.reportedCompletions == nil {
+			clt.reportedCompletions = make(map[string]float64)
+		}
+		lastPct, reported := clt.reportedCompletions[key]
+		changed := !reported || math.Abs(pct-lastPct) >= folderCompletionReportThreshold || (pct == 100.0 && lastPct != 100.0)
+		if changed {
+			clt.reportedCompletions[key] = pct
+		}
+
+		if changed && !clt.IgnoreEvents && clt.Delegate != nil {
+			clt.mutex.Unlock()
+			clt.Delegate.OnFolderCompletionChanged(folder, device, pct)
+		} else {
+			clt.mutex.Unlock()
+		}
+
+	case events.FolderScanProgress:
+		data := evt.Data.(map[string]interface{})
+		folder := data["folder"].(string)
+
+		clt.mutex.Lock()
+		if clt.scanProgress == nil {
+			clt.scanProgress = make(map[string]*ScanProgress)
+		}
+		clt.scanProgress[folder] = &ScanProgress{
+			Current:        data["current"].(int64),
+			Total:          data["total"].(int64),
+			BytesPerSecond: data["rate"].(float64),
+		}
+		if !clt.IgnoreEvents && clt.Delegate != nil {
+			clt.mutex.Unlock()
+			clt.Delegate.OnEvent(evt.Type.String())
+		} else {
+			clt.mutex.Unlock()
+		}
+
+	case events.FolderErrors:
+		data := evt.Data.(map[string]interface{})
+		clt.setFolderErrors(data["folder"].(string), data["errors"].([]model.FileError))
+
+	case events.FolderSummary:
+		data := evt.Data.(model.FolderSummaryEventData)
+		if data.Summary != nil {
+			clt.clearFolderErrorsIfResolved(data.Folder, data.Summary.Errors)
+		}
+
 	case events.ItemFinished, events.ItemStarted:
 		// Ignore these events
 		break
@@ -574,6 +839,16 @@ func (clt *Client) HasMigratedLegacyDatabase() bool {
 	return true
 }
 
+// Returns the version of the database format in use: 2 once the database has been loaded (a legacy database is always
+// migrated when loading), or 1 when a legacy database is present that has not yet been migrated. When this returns 2,
+// a remaining legacy database (see HasLegacyDatabase and HasMigratedLegacyDatabase) is redundant and can be cleared.
+func (clt *Client) ActiveDatabaseVersion() int {
+	if clt.app == nil && clt.HasLegacyDatabase() {
+		return 1
+	}
+	return 2
+}
+
 // This method loads and migrates the Syncthing database. It also starts the streaming web
 // server. This method can take a while to complete and should only ever be called once.
 func (clt *Client) Load(resetDeltaIdxs bool) error {
@@ -598,17 +873,17 @@ func (clt *Client) Load(resetDeltaIdxs bool) error {
 	)
 	if err != nil {
 		clt.cancel()
-		return err
+		return startError(ErrCertificateUnreadable, err)
 	}
 	clt.cert = &cert
 
 	// Load or create the config
 	devID := protocol.NewDeviceID(cert.Certificate[0])
 	slog.Info("loading config file", "path", locations.Get(locations.ConfigFile))
-	config, err := loadOrDefaultConfig(devID, clt.ctx, clt.evLogger, clt.filesPath)
+	config, err := loadOrDefaultConfig(devID, clt.ctx, clt.evLogger, clt.filesPath, clt.ReportScanProgress)
 	if err != nil {
 		clt.cancel()
-		return err
+		return startError(ErrConfigUnreadable, err)
 	}
 	clt.config = config
 
@@ -617,9 +892,9 @@ func (clt *Client) Load(resetDeltaIdxs bool) error {
 	slog.Info("Attempting to obtain application lock at", "path", locations.Get(locations.LockFile))
 	locked, err := clt.appLock.TryLock()
 	if err != nil {
-		return fmt.Errorf("failed to obtain lock: %w", err)
+		return startError(ErrAlreadyRunning, fmt.Errorf("failed to obtain lock: %w", err))
 	} else if !locked {
-		return fmt.Errorf("The app cannot be started, as it appears it is already running. If this error persists, try restarting your device.")
+		return fmt.Errorf("%w. If this error persists, try restarting your device.", ErrAlreadyRunning)
 	}
 
 	// Default retention interval taken from Syncthing's CLI default
@@ -627,7 +902,7 @@ func (clt *Client) Load(resetDeltaIdxs bool) error {
 	// It really wants to set up a temporary API while migrating...
 	if err := syncthing.TryMigrateDatabase(clt.ctx, dbDeleteRetentionInterval); err != nil {
 		slog.Warn("failed to migrate legacy database", "cause", err)
-		return err
+		return startError(ErrDatabaseUnavailable, err)
 	}
 
 	appOpts := syncthing.Options{
@@ -644,12 +919,12 @@ func (clt *Client) Load(resetDeltaIdxs bool) error {
 
 	sdb, err := syncthing.OpenDatabase(dbPath, dbDeleteRetentionInterval)
 	if err != nil {
-		return err
+		return startError(ErrDatabaseUnavailable, err)
 	}
 
 	app, err := syncthing.New(clt.config, sdb, clt.evLogger, *clt.cert, appOpts)
 	if err != nil {
-		return err
+		return startError(ErrDatabaseUnavailable, err)
 	}
 	clt.app = app
 
@@ -662,25 +937,108 @@ func (clt *Client) Start() error {
 	}
 
 	clt.Measurements = NewMeasurements(clt)
+	clt.mutex.Lock()
+	clt.startedAt = time.Now()
+	clt.mutex.Unlock()
 
 	// Set up streaming server
 	server, err := NewServer(clt.app, clt.Measurements, clt.ctx)
 	if err != nil {
-		return err
+		return startError(ErrServerUnavailable, err)
 	}
 	server.client = clt
 	clt.Server = server
+	if clt.PersistStreamingKey {
+		if privateKey, err := loadOrGenerateSigningKey(path.Join(clt.CurrentConfigDirectory(), streamingKeyFileName)); err != nil {
+			slog.Warn("could not load persisted streaming key, using a temporary key", "cause", err)
+		} else {
+			server.setSigningKey(privateKey)
+		}
+	}
 
 	// Subscribe to events
 	go clt.startEventListener()
+	go clt.startBandwidthScheduler()
+	clt.loadCellularPolicy()
+	clt.loadTimedPauses()
+	clt.loadConflictPolicies()
 
 	if err := clt.app.Start(); err != nil {
-		return err
+		return startError(nil, err)
 	}
 
 	return nil
 }
 
+// Wraps an error encountered while loading or starting the client with the sentinel error for the stage that failed.
+// Running out of disk space and database corruption are detected regardless of stage, as the remedy is the same. When
+// stage is nil and neither is detected, err is returned as-is.
+func startError(stage error, err error) error {
+	if errors.Is(err, syscall.ENOSPC) {
+		stage = ErrDiskFull
+	} else if isDatabaseCorruptError(err) {
+		stage = ErrDatabaseCorrupt
+	}
+	if stage == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", stage, err)
+}
+
+// SQLite reports corruption as SQLITE_CORRUPT or SQLITE_NOTADB, which only surface in the error message
+func isDatabaseCorruptError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "malformed") || strings.Contains(msg, "file is not a database") ||
+		strings.Contains(msg, "sqlite_corrupt") || strings.Contains(msg, "sqlite_notadb")
+}
+
+// Returns a short identifier for the kind of error returned by Client.Load or Client.Start, which the UI can use to
+// suggest a remedy: "diskFull", "databaseCorrupt", "databaseUnavailable", "certificateUnreadable",
+// "configUnreadable", "alreadyRunning", "serverUnavailable" or "unknown". Returns an empty string for a nil error. The
+// error message itself is left intact and should still be logged.
+func StartErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrDiskFull), errors.Is(err, syscall.ENOSPC):
+		return "diskFull"
+	case errors.Is(err, ErrDatabaseCorrupt):
+		return "databaseCorrupt"
+	case errors.Is(err, ErrDatabaseUnavailable):
+		return "databaseUnavailable"
+	case errors.Is(err, ErrCertificateUnreadable):
+		return "certificateUnreadable"
+	case errors.Is(err, ErrConfigUnreadable):
+		return "configUnreadable"
+	case errors.Is(err, ErrAlreadyRunning):
+		return "alreadyRunning"
+	case errors.Is(err, ErrServerUnavailable):
+		return "serverUnavailable"
+	default:
+		return "unknown"
+	}
+}
+
+// The time at which the client was (last) started, or nil when it has not been started yet
+func (clt *Client) StartedAt() *Date {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	if clt.startedAt.IsZero() {
+		return nil
+	}
+	return &Date{time: clt.startedAt}
+}
+
+// The number of seconds since the client was started, or zero when it has not been started yet
+func (clt *Client) UptimeSeconds() int64 {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	if clt.startedAt.IsZero() {
+		return 0
+	}
+	return int64(time.Since(clt.startedAt).Seconds())
+}
+
 func (clt *Client) PerformMaintenanceBlocking() error {
 	return <-clt.app.StartMaintenance()
 }
@@ -706,7 +1064,7 @@ func (clt *Client) SetFSWatchingEnabledForAllFolders(enabled bool) {
 	})
 }
 
-func loadOrDefaultConfig(devID protocol.DeviceID, ctx context.Context, logger events.Logger, filesPath string) (config.Wrapper, error) {
+func loadOrDefaultConfig(devID protocol.DeviceID, ctx context.Context, logger events.Logger, filesPath string, reportScanProgress bool) (config.Wrapper, error) {
 	cfgFile := locations.Get(locations.ConfigFile)
 	cfg, _, err := config.Load(cfgFile, devID, logger)
 	if err != nil {
@@ -755,9 +1113,13 @@ func loadOrDefaultConfig(devID protocol.DeviceID, ctx context.Context, logger ev
 				}
 			}
 
-			// The app currently doesn't do anything with scan progress update events, and enabling progress reports apparently
-			// leads to some extra memory usage (see https://github.com/syncthing/syncthing/pull/10221), so disable it.
-			if folderConfig.ScanProgressIntervalS >= 0 {
+			// Enabling progress reports apparently leads to some extra memory usage (see
+			// https://github.com/syncthing/syncthing/pull/10221), so disable them unless the app wants to show scan
+			// progress (see Client.ReportScanProgress), in which case the default interval is used.
+			if reportScanProgress && folderConfig.ScanProgressIntervalS < 0 {
+				folderConfig.ScanProgressIntervalS = 0
+				conf.SetFolder(folderConfig)
+			} else if !reportScanProgress && folderConfig.ScanProgressIntervalS >= 0 {
 				folderConfig.ScanProgressIntervalS = -1
 				conf.SetFolder(folderConfig)
 			}
@@ -801,6 +1163,41 @@ func (clt *Client) Folders() *ListOfStrings {
 	}))
 }
 
+// Sets the paused state of the folders in place, and returns the IDs of the folders whose state changed
+func setFoldersPaused(folders []config.FolderConfiguration, paused bool) []string {
+	changed := make([]string, 0)
+	for idx := range folders {
+		if folders[idx].Paused != paused {
+			folders[idx].Paused = paused
+			changed = append(changed, folders[idx].ID)
+		}
+	}
+	return changed
+}
+
+// Pauses or resumes all folders in a single configuration change. Returns the IDs of the folders whose state actually
+// changed, so that exactly these can be resumed later (leaving folders that were paused by the user paused).
+func (clt *Client) SetAllFoldersPaused(paused bool) (*ListOfStrings, error) {
+	if clt.config == nil {
+		return nil, ErrStillLoading
+	}
+
+	var changed []string
+	err := clt.changeConfiguration(func(cfg *config.Configuration) {
+		changed = setFoldersPaused(cfg.Folders, paused)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !paused {
+		for _, folderID := range changed {
+			clt.cancelTimedPause(folderID)
+		}
+	}
+	return List(changed), nil
+}
+
 func (clt *Client) FolderWithID(id string) *Folder {
 	if clt.config == nil {
 		return nil
@@ -839,6 +1236,31 @@ func (clt *Client) ConnectedPeerCount() int {
 	return connected
 }
 
+type TransferStats struct {
+	InBytes         int64 // Bytes received, or -1 when unknown
+	OutBytes        int64 // Bytes sent, or -1 when unknown
+	DurationSeconds int64 // The period over which the bytes were counted
+}
+
+/*
+* Returns the amount of data exchanged with all peers since the client was started. This includes protocol overhead and
+data exchanged with peers that have since disconnected, which makes it suitable for showing data used this session.
+*/
+func (clt *Client) TotalTransferStatistics() *TransferStats {
+	inBytes, outBytes := protocol.TotalInOut()
+	stats := &TransferStats{
+		InBytes:  inBytes,
+		OutBytes: outBytes,
+	}
+
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	if !clt.startedAt.IsZero() {
+		stats.DurationSeconds = int64(time.Since(clt.startedAt).Seconds())
+	}
+	return stats
+}
+
 func (clt *Client) Peers() *ListOfStrings {
 	if clt.config == nil {
 		return nil
@@ -900,14 +1322,41 @@ func (clt *Client) SetDevicesPaused(peers *ListOfStrings, pause bool) error {
 }
 
 func (clt *Client) changeConfiguration(block config.ModifyFunction) error {
+	// Prevent our own changes from interleaving, so that a rollback only undoes the change that failed to save
+	clt.configChangeMutex.Lock()
+	defer clt.configChangeMutex.Unlock()
+
+	before := clt.config.RawCopy()
 	waiter, err := clt.config.Modify(block)
 	if err != nil {
 		return err
 	}
+	after := clt.config.RawCopy()
 	waiter.Wait()
 
-	err = clt.config.Save()
-	return err
+	if err := clt.config.Save(); err != nil {
+		// Roll back so that the running configuration does not diverge from what is on disk
+		slog.Warn("could not save configuration, rolling back change", "cause", err)
+		if rollbackWaiter, rollbackErr := clt.config.Modify(func(cfg *config.Configuration) {
+			// Syncthing itself may have changed the configuration in the meantime; do not discard those changes
+			if !reflect.DeepEqual(*cfg, after) {
+				slog.Error("configuration was changed concurrently, not rolling back")
+				return
+			}
+			*cfg = before
+		}); rollbackErr != nil {
+			slog.Error("could not roll back configuration change", "cause", rollbackErr)
+		} else {
+			rollbackWaiter.Wait()
+		}
+		return fmt.Errorf("%w: %w", ErrConfigSaveFailed, err)
+	}
+	return nil
+}
+
+// Returns true when the error indicates that a configuration change could not be saved (and was rolled back)
+func IsConfigSaveError(err error) bool {
+	return errors.Is(err, ErrConfigSaveFailed)
 }
 
 func (clt *Client) AddPeer(deviceID string) error {
@@ -924,11 +1373,77 @@ func (clt *Client) AddPeer(deviceID string) error {
 	})
 }
 
+/*
+* Adds a peer and shares the given folders with it in a single configuration change, so that the peer never exists
+without its folders. When `addresses` is nil or empty, the peer's addresses are discovered dynamically.
+*/
+func (clt *Client) AddPeerSharingFolders(deviceID string, folderIDs *ListOfStrings, addresses *ListOfStrings) error {
+	if clt.config == nil {
+		return ErrStillLoading
+	}
+
+	addedDevice, err := protocol.DeviceIDFromString(deviceID)
+	if err != nil {
+		return err
+	}
+
+	deviceConfig := clt.config.DefaultDevice()
+	deviceConfig.DeviceID = addedDevice
+	if addresses != nil && len(addresses.data) > 0 {
+		deviceConfig.Addresses = slices.Clone(addresses.data)
+	}
+
+	// Check that all folders exist before changing anything
+	folders := clt.config.Folders()
+	if folderIDs != nil {
+		for _, folderID := range folderIDs.data {
+			if _, ok := folders[folderID]; !ok {
+				return fmt.Errorf("folder does not exist: '%s'", folderID)
+			}
+		}
+	}
+
+	return clt.changeConfiguration(func(cfg *config.Configuration) {
+		cfg.SetDevice(deviceConfig)
+		if folderIDs == nil {
+			return
+		}
+
+		for _, folderID := range folderIDs.data {
+			fc, _, ok := cfg.Folder(folderID)
+			if !ok {
+				continue
+			}
+			if slices.ContainsFunc(fc.Devices, func(fdc config.FolderDeviceConfiguration) bool {
+				return fdc.DeviceID == addedDevice
+			}) {
+				continue
+			}
+			fc.Devices = append(fc.Devices, config.FolderDeviceConfiguration{DeviceID: addedDevice})
+			cfg.SetFolder(fc)
+		}
+	})
+}
+
+// Returns the names of the filesystem types that can be used for folders (the built-in types as well as types
+// registered with RegisterCustomFilesystemType)
+func (clt *Client) RegisteredFilesystemTypes() *ListOfStrings {
+	types := []string{string(config.FilesystemTypeBasic), string(config.FilesystemTypeFake)}
+
+	customFilesystemTypesMutex.Lock()
+	defer customFilesystemTypesMutex.Unlock()
+	return List(append(types, customFilesystemTypes...))
+}
+
 func (clt *Client) AddSpecialFolder(folderID string, fsType string, folderPath string, folderType string) error {
 	if clt.app == nil || clt.app.Internals == nil {
 		return ErrStillLoading
 	}
 
+	if !slices.Contains(clt.RegisteredFilesystemTypes().data, fsType) {
+		return fmt.Errorf("unknown filesystem type: '%s'", fsType)
+	}
+
 	ft := config.FolderTypeSendReceive
 	ft.UnmarshalText([]byte(folderType))
 
@@ -997,6 +1512,112 @@ func (clt *Client) AddFolder(folderID string, folderPath string, createAsOnDeman
 	}
 }
 
+type AddFolderPreview struct {
+	Path                string // The path the folder would be created at
+	PathExists          bool
+	IsDirectory         bool
+	HasMarker           bool   // The directory contains a folder marker, i.e. it was used as a folder before
+	HasIgnoreFile       bool   // The directory contains an ignore file, which would be replaced
+	FolderExists        bool   // A folder with this ID is already configured
+	OverlappingFolderID string // ID of an existing folder whose path contains, or is contained in, the path
+	FileCount           int    // Existing files (excluding the folder marker) that would be scanned and synced initially
+	DirectoryCount      int
+	TotalBytes          int64
+}
+
+/*
+* Reports what AddFolder would do with the same folder ID and path, without changing anything. When the path already
+exists, it is walked to count the files that would be picked up by the initial scan, which may take a while for large
+directories.
+*/
+func (clt *Client) PreviewAddFolder(folderID string, folderPath string) (*AddFolderPreview, error) {
+	if clt.config == nil {
+		return nil, ErrStillLoading
+	}
+
+	if len(folderPath) == 0 {
+		folderPath = path.Join(clt.filesPath, folderID)
+	}
+
+	preview, err := previewFolderPath(folderPath, clt.config.DefaultFolder().MarkerName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fc := range clt.config.FolderList() {
+		if fc.ID == folderID {
+			preview.FolderExists = true
+		}
+		if preview.OverlappingFolderID == "" && fc.FilesystemType == config.FilesystemTypeBasic && pathsOverlap(fc.Path, folderPath) {
+			preview.OverlappingFolderID = fc.ID
+		}
+	}
+	return preview, nil
+}
+
+// Returns true when either path is equal to, or contains the other path
+func pathsOverlap(a string, b string) bool {
+	a = filepath.Clean(a)
+	b = filepath.Clean(b)
+	return a == b || strings.HasPrefix(a, b+string(filepath.Separator)) || strings.HasPrefix(b, a+string(filepath.Separator))
+}
+
+func previewFolderPath(folderPath string, markerName string) (*AddFolderPreview, error) {
+	preview := &AddFolderPreview{Path: folderPath}
+
+	stat, err := os.Stat(folderPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return preview, nil
+	} else if err != nil {
+		return nil, err
+	}
+	preview.PathExists = true
+	preview.IsDirectory = stat.IsDir()
+	if !preview.IsDirectory {
+		return preview, nil
+	}
+
+	err = filepath.WalkDir(folderPath, func(entryPath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entryPath == folderPath {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(folderPath, entryPath)
+		if err != nil {
+			return err
+		}
+		switch relativePath {
+		case markerName:
+			preview.HasMarker = true
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case ignoreFileName:
+			preview.HasIgnoreFile = true
+			return nil
+		}
+
+		if d.IsDir() {
+			preview.DirectoryCount++
+			return nil
+		}
+
+		preview.FileCount++
+		if info, err := d.Info(); err == nil {
+			preview.TotalBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
 func (clt *Client) SetNATEnabled(enabled bool) error {
 	return clt.changeConfiguration(func(cfg *config.Configuration) {
 		cfg.Options.NATEnabled = enabled
@@ -1301,12 +1922,22 @@ func zipError[T any](it iter.Seq[T], errFn func() error) iter.Seq2[T, error] {
 particular order, unless/until the delegate returns true from IsCancelled. Set maxResults to <=0 to collect all results.
 */
 func (clt *Client) Search(text string, delegate SearchResultDelegate, maxResults int, folderID string, prefix string) error {
+	return clt.SearchWithFilter(text, delegate, maxResults, folderID, prefix, nil)
+}
+
+/*
+* Like Search, but only returns files of the specified types. Each item in `fileTypes` is either a file type category
+(e.g. "image", "video", "audio" or "document") or an extension (e.g. "pdf" or ".docx"). When `fileTypes` is nil or
+empty, files of all types are returned.
+*/
+func (clt *Client) SearchWithFilter(text string, delegate SearchResultDelegate, maxResults int, folderID string, prefix string, fileTypes *ListOfStrings) error {
 	if clt.app == nil || clt.app.Internals == nil {
 		return ErrStillLoading
 	}
 
 	text = strings.ToLower(text)
 	resultCount := 0
+	filter := newSearchFileTypeFilter(fileTypes)
 
 	for _, folder := range clt.config.FolderList() {
 		if folderID != "" && folder.ID != folderID {
@@ -1338,7 +1969,7 @@ func (clt *Client) Search(text string, delegate SearchResultDelegate, maxResults
 			pathParts := strings.Split(f.Name, "/")
 			lowerFileName := strings.ToLower(pathParts[len(pathParts)-1])
 
-			if gimmeMore && !f.Deleted && strings.Contains(lowerFileName, text) {
+			if gimmeMore && !f.Deleted && strings.Contains(lowerFileName, text) && filter.matches(lowerFileName) {
 				entry, err := folderObject.GetFileInformation(f.Name)
 				if err == nil {
 					resultCount += 1
@@ -1448,6 +2079,70 @@ func (clt *Client) PendingFolderIDs() (*ListOfStrings, error) {
 	return List(KeysOf(pfs)), nil
 }
 
+type PendingFolder struct {
+	ID                 string   `json:"id"`
+	Label              string   `json:"label"`              // Label suggested by the device that offered the folder most recently
+	OfferedByDeviceIDs []string `json:"offeredByDeviceIDs"` // Sorted
+	ReceiveEncrypted   bool     `json:"receiveEncrypted"`   // True when any device offers the folder as encrypted folder
+}
+
+// Returns details on all folders offered to us that have not been added yet, sorted by folder ID
+func (clt *Client) PendingFolderDetails() ([]*PendingFolder, error) {
+	if clt.app == nil || clt.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	pending := map[string]*PendingFolder{}
+	labelTimes := map[string]time.Time{}
+	for _, peer := range clt.config.DeviceList() {
+		peerPendingFolders, err := clt.app.Internals.PendingFolders(peer.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+
+		for folderID, peerPendingFolder := range peerPendingFolders {
+			pf, ok := pending[folderID]
+			if !ok {
+				pf = &PendingFolder{ID: folderID, OfferedByDeviceIDs: []string{}}
+				pending[folderID] = pf
+			}
+
+			for offeringDeviceID, offer := range peerPendingFolder.OfferedBy {
+				offeringDevice := offeringDeviceID.String()
+				if !slices.Contains(pf.OfferedByDeviceIDs, offeringDevice) {
+					pf.OfferedByDeviceIDs = append(pf.OfferedByDeviceIDs, offeringDevice)
+				}
+				if offer.ReceiveEncrypted {
+					pf.ReceiveEncrypted = true
+				}
+				if offer.Label != "" && !offer.Time.Before(labelTimes[folderID]) {
+					pf.Label = offer.Label
+					labelTimes[folderID] = offer.Time
+				}
+			}
+		}
+	}
+
+	details := make([]*PendingFolder, 0, len(pending))
+	for _, pf := range pending {
+		slices.Sort(pf.OfferedByDeviceIDs)
+		details = append(details, pf)
+	}
+	slices.SortFunc(details, func(a *PendingFolder, b *PendingFolder) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return details, nil
+}
+
+// Returns the result of PendingFolderDetails as a JSON array
+func (clt *Client) PendingFolderDetailsJSON() ([]byte, error) {
+	details, err := clt.PendingFolderDetails()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(details)
+}
+
 func (clt *Client) DevicesPendingFolder(folderID string) (*ListOfStrings, error) {
 	if clt.app == nil || clt.app.Internals == nil {
 		return nil, ErrStillLoading
@@ -1464,6 +2159,99 @@ func (clt *Client) DevicesPendingFolder(folderID string) (*ListOfStrings, error)
 	return List([]string{}), nil
 }
 
+// Automatically accept folders offered by the device with the specified ID, adding them at pathTemplate, in which
+// "{folderID}" is replaced with the ID of the folder. Relative paths are relative to the files directory. Set the
+// template to an empty string to stop auto-accepting folders from the device. This setting is not persisted.
+func (clt *Client) SetAutoAcceptFolders(deviceID string, pathTemplate string) error {
+	devID, err := protocol.DeviceIDFromString(deviceID)
+	if err != nil {
+		return err
+	}
+
+	if pathTemplate != "" && !strings.Contains(pathTemplate, "{folderID}") {
+		return errors.New("path template must contain {folderID}")
+	}
+
+	clt.mutex.Lock()
+	if clt.autoAcceptFolderPaths == nil {
+		clt.autoAcceptFolderPaths = make(map[string]string)
+	}
+	if pathTemplate == "" {
+		delete(clt.autoAcceptFolderPaths, devID.String())
+	} else {
+		clt.autoAcceptFolderPaths[devID.String()] = pathTemplate
+	}
+	clt.mutex.Unlock()
+
+	// Folders may already have been offered
+	if pathTemplate != "" && clt.app != nil && clt.app.Internals != nil {
+		go clt.autoAcceptPendingFolders()
+	}
+	return nil
+}
+
+func (clt *Client) autoAcceptPendingFolders() {
+	if clt.app == nil || clt.app.Internals == nil {
+		return
+	}
+
+	// Prevent accepting the same folder twice when multiple events arrive in quick succession
+	clt.autoAcceptMutex.Lock()
+	defer clt.autoAcceptMutex.Unlock()
+
+	clt.mutex.Lock()
+	templates := maps.Clone(clt.autoAcceptFolderPaths)
+	clt.mutex.Unlock()
+
+	for deviceID, pathTemplate := range templates {
+		devID, err := protocol.DeviceIDFromString(deviceID)
+		if err != nil {
+			continue
+		}
+
+		pendingFolders, err := clt.app.Internals.PendingFolders(devID)
+		if err != nil {
+			slog.Warn("could not obtain pending folders for auto-accept", "deviceID", deviceID, "cause", err)
+			continue
+		}
+
+		for folderID, pendingFolder := range pendingFolders {
+			offer, ok := pendingFolder.OfferedBy[devID]
+			if !ok || clt.FolderWithID(folderID) != nil {
+				continue
+			}
+
+			sanitizedID := fs.SanitizePath(folderID)
+			if sanitizedID == "" {
+				slog.Warn("cannot auto-accept folder with this ID", "folderID", folderID, "deviceID", deviceID)
+				continue
+			}
+
+			folderPath := strings.ReplaceAll(pathTemplate, "{folderID}", sanitizedID)
+			if !path.IsAbs(folderPath) {
+				folderPath = path.Join(clt.filesPath, folderPath)
+			}
+
+			slog.Info("auto-accepting folder", "folderID", folderID, "deviceID", deviceID, "path", folderPath)
+			if err := clt.AddFolder(folderID, folderPath, false, offer.ReceiveEncrypted); err != nil {
+				slog.Warn("could not auto-accept folder", "folderID", folderID, "cause", err)
+				continue
+			}
+
+			fld := clt.FolderWithID(folderID)
+			if fld == nil {
+				continue
+			}
+			if offer.Label != "" {
+				fld.SetLabel(offer.Label)
+			}
+			if err := fld.ShareWithDevice(deviceID, true, ""); err != nil {
+				slog.Warn("could not share auto-accepted folder with device", "folderID", folderID, "cause", err)
+			}
+		}
+	}
+}
+
 func (clt *Client) SetReconnectIntervalS(secs int) error {
 	slog.Info("set reconnect interval", "interval", secs)
 	return clt.changeConfiguration(func(cfg *config.Configuration) {
@@ -1608,11 +2396,7 @@ func (c *Client) generateSupportBundle(writer io.Writer, appInfo []byte) error {
 	// Write app support info
 	if len(appInfo) > 0 {
 		appInfo = []byte(redactLog(string(appInfo)))
-		appInfoWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
-			Name:     "info-app.json",
-			Modified: time.Now(),
-			Method:   zip.Deflate,
-		})
+		appInfoWriter, err := createZipFile(zipWriter, "info-app.json")
 		if err != nil {
 			return err
 		}
@@ -1623,11 +2407,7 @@ func (c *Client) generateSupportBundle(writer io.Writer, appInfo []byte) error {
 	}
 
 	// Write log tail
-	logTailFileWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
-		Name:     "log-tail.txt",
-		Modified: time.Now(),
-		Method:   zip.Deflate,
-	})
+	logTailFileWriter, err := createZipFile(zipWriter, "log-tail.txt")
 	if err != nil {
 		return err
 	}
@@ -1642,7 +2422,9 @@ func (c *Client) generateSupportBundle(writer io.Writer, appInfo []byte) error {
 	infoJson["shortDeviceID"] = c.ShortDeviceID()
 	infoJson["isUsingCustomConfiguration"] = c.IsUsingCustomConfiguration
 	infoJson["isIgnoringEvents"] = c.IgnoreEvents
-	infoJson["extraneousIgnored"] = c.extraneousIgnored
+	c.mutex.Lock()
+	infoJson["extraneousIgnored"] = slices.Concat(c.extraneousIgnored, c.extraneousIgnorePatterns)
+	c.mutex.Unlock()
 	infoJson["hasLegacyDatabase"] = c.HasLegacyDatabase()
 	infoJson["hasMigratedLegacyDatabase"] = c.HasMigratedLegacyDatabase()
 	infoJson["connectedPeerCount"] = c.ConnectedPeerCount()
@@ -1667,11 +2449,7 @@ func (c *Client) generateSupportBundle(writer io.Writer, appInfo []byte) error {
 		return err
 	}
 	jsonData = []byte(redactLog(string(jsonData)))
-	jsonWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
-		Name:     "info.json",
-		Modified: time.Now(),
-		Method:   zip.Deflate,
-	})
+	jsonWriter, err := createZipFile(zipWriter, "info.json")
 	if err != nil {
 		return err
 	}
@@ -1682,11 +2460,7 @@ func (c *Client) generateSupportBundle(writer io.Writer, appInfo []byte) error {
 
 	// Goroutine profile
 	if p := pprof.Lookup("goroutine"); p != nil {
-		goroutineWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
-			Name:     "goroutines.pprof",
-			Modified: time.Now(),
-			Method:   zip.Deflate,
-		})
+		goroutineWriter, err := createZipFile(zipWriter, "goroutines.pprof")
 		if err != nil {
 			return err
 		}
@@ -1699,6 +2473,15 @@ func (c *Client) generateSupportBundle(writer io.Writer, appInfo []byte) error {
 	return nil
 }
 
+// Adds a compressed file with the given name to the archive and returns a writer for its contents
+func createZipFile(zipWriter *zip.Writer, name string) (io.Writer, error) {
+	return zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Modified: time.Now(),
+		Method:   zip.Deflate,
+	})
+}
+
 func (c *Client) getRedactedConfigFile() config.Configuration {
 	rawConf := c.config.RawCopy()
 	rawConf.GUI.APIKey = "•••"
@@ -1721,6 +2504,14 @@ func (c *Client) getRedactedConfigFile() config.Configuration {
 	return rawConf
 }
 
+// Returns the currently running configuration (options, folders, devices) as JSON, with secrets redacted
+func (clt *Client) EffectiveConfigJSON() ([]byte, error) {
+	if clt.config == nil {
+		return nil, ErrStillLoading
+	}
+	return json.MarshalIndent(clt.getRedactedConfigFile(), "", "\t")
+}
+
 func (clt *Client) IsNetworkTrafficLowPriority() bool {
 	return clt.config.Options().TrafficClass == 4
 }
@@ -0,0 +1,206 @@
+package sushitrain
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/locations"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestPreviewFolderPath(t *testing.T) {
+	root := t.TempDir()
+
+	preview, err := previewFolderPath(filepath.Join(root, "missing"), ".stfolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.PathExists || preview.FileCount != 0 {
+		t.Errorf("unexpected preview for missing path: %+v", preview)
+	}
+
+	dir := filepath.Join(root, "folder")
+	for name, contents := range map[string]string{
+		".stfolder/marker": "",
+		".stignore":        "*.tmp",
+		"a.txt":            "hello",
+		"sub/b.txt":        "world!",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	preview, err = previewFolderPath(dir, ".stfolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !preview.PathExists || !preview.IsDirectory || !preview.HasMarker || !preview.HasIgnoreFile {
+		t.Errorf("unexpected flags in preview: %+v", preview)
+	}
+	if preview.FileCount != 2 || preview.DirectoryCount != 1 || preview.TotalBytes != 11 {
+		t.Errorf("unexpected counts in preview: %+v", preview)
+	}
+}
+
+func TestPathsOverlap(t *testing.T) {
+	cases := map[[2]string]bool{
+		{"/a/b", "/a/b"}:    true,
+		{"/a/b", "/a/b/c"}:  true,
+		{"/a/b/c", "/a/b/"}: true,
+		{"/a/b", "/a/bc"}:   false,
+		{"/a/b", "/x"}:      false,
+	}
+	for paths, overlap := range cases {
+		if pathsOverlap(paths[0], paths[1]) != overlap {
+			t.Errorf("pathsOverlap(%q, %q) should be %t", paths[0], paths[1], overlap)
+		}
+	}
+}
+
+func TestChangeConfigurationRollsBackWhenSaveFails(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Saving fails because the directory the configuration should be written to does not exist
+	cfgPath := filepath.Join(t.TempDir(), "missing", "config.xml")
+	cfg := config.New(protocol.LocalDeviceID)
+	cfg.Options.MaxRecvKbps = 300
+	wrapper := config.Wrap(cfgPath, cfg, protocol.LocalDeviceID, events.NoopLogger)
+	go wrapper.Serve(ctx)
+
+	clt := &Client{config: wrapper}
+	err := clt.changeConfiguration(func(cfg *config.Configuration) {
+		cfg.Options.MaxRecvKbps = 700
+	})
+	if !IsConfigSaveError(err) {
+		t.Fatalf("expected save error, got %v", err)
+	}
+	if maxRecvKbps := wrapper.Options().MaxRecvKbps; maxRecvKbps != 300 {
+		t.Errorf("expected change to be rolled back, got %d", maxRecvKbps)
+	}
+}
+
+func TestSetFoldersPaused(t *testing.T) {
+	folders := []config.FolderConfiguration{
+		{ID: "a", Paused: false},
+		{ID: "b", Paused: true},
+		{ID: "c", Paused: false},
+	}
+
+	changed := setFoldersPaused(folders, true)
+	if !slices.Equal(changed, []string{"a", "c"}) {
+		t.Errorf("unexpected changed folders when pausing: %v", changed)
+	}
+	for _, fc := range folders {
+		if !fc.Paused {
+			t.Errorf("folder %s should be paused", fc.ID)
+		}
+	}
+
+	if changed := setFoldersPaused(folders, true); len(changed) != 0 {
+		t.Errorf("pausing again should not change anything: %v", changed)
+	}
+
+	if changed := setFoldersPaused(folders, false); !slices.Equal(changed, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected changed folders when resuming: %v", changed)
+	}
+}
+
+func TestExtraneousIgnorePatterns(t *testing.T) {
+	clt := &Client{extraneousIgnored: []string{"exact[name"}}
+	if err := clt.AddExtraneousIgnorePattern("*.tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := clt.AddExtraneousIgnorePattern("[invalid"); err == nil {
+		t.Error("invalid patterns should be rejected")
+	}
+	clt.ApplyDefaultJunkIgnores()
+
+	// Setting the list of ignored names (as the app does when applying its settings) keeps added patterns
+	clt.SetExtraneousIgnored([]string{"exact[name"})
+
+	for _, name := range []string{"exact[name", "file.tmp", ".DS_Store", "._photo.jpg", "Thumbs.db", "Icon\r", ".syncthing.x.tmp"} {
+		if !clt.isExtraneousIgnored(name) {
+			t.Errorf("%q should be ignored", name)
+		}
+	}
+	for _, name := range []string{"photo.jpg", "file.tmp.jpg", "Icon", "exact"} {
+		if clt.isExtraneousIgnored(name) {
+			t.Errorf("%q should not be ignored", name)
+		}
+	}
+}
+
+func TestLoadConfigScanProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	previousConfigDir := locations.GetBaseDir(locations.ConfigBaseDir)
+	t.Cleanup(func() {
+		locations.SetBaseDir(locations.ConfigBaseDir, previousConfigDir)
+	})
+
+	for _, reportScanProgress := range []bool{false, true} {
+		if err := locations.SetBaseDir(locations.ConfigBaseDir, t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := config.New(protocol.LocalDeviceID)
+		fc := cfg.Defaults.Folder.Copy()
+		fc.ID = "abc"
+		fc.Path = t.TempDir()
+		fc.ScanProgressIntervalS = 0
+		if reportScanProgress {
+			// Progress reports were disabled when loading earlier without reporting scan progress
+			fc.ScanProgressIntervalS = -1
+		}
+		cfg.SetFolder(fc)
+		wrapper := config.Wrap(locations.Get(locations.ConfigFile), cfg, protocol.LocalDeviceID, events.NoopLogger)
+		if err := wrapper.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := loadOrDefaultConfig(protocol.LocalDeviceID, ctx, events.NoopLogger, t.TempDir(), reportScanProgress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		folder, ok := loaded.Folder("abc")
+		if !ok {
+			t.Fatal("folder not found in loaded configuration")
+		}
+		if enabled := folder.ScanProgressIntervalS >= 0; enabled != reportScanProgress {
+			t.Errorf("scan progress reports enabled should be %t, interval is %d", reportScanProgress, folder.ScanProgressIntervalS)
+		}
+	}
+}
+
+func TestUniqueExportPath(t *testing.T) {
+	dir := t.TempDir()
+
+	exportPath, err := uniqueExportPath(dir, "file.txt")
+	if err != nil || exportPath != filepath.Join(dir, "file.txt") {
+		t.Fatalf("unexpected path for new file: %s, %v", exportPath, err)
+	}
+	if err := os.WriteFile(exportPath, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	exportPath, err = uniqueExportPath(dir, "file.txt")
+	if err != nil || exportPath != filepath.Join(dir, "file (1).txt") {
+		t.Errorf("unexpected path for existing file: %s, %v", exportPath, err)
+	}
+
+	// Errors other than the file not existing should be reported instead of trying other names forever
+	if _, err := uniqueExportPath(filepath.Join(dir, "file.txt"), "other.txt"); err == nil {
+		t.Error("expected error when the export directory is a file")
+	}
+}
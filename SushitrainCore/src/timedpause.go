@@ -0,0 +1,130 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+)
+
+const timedPausesFileName = "timed-pauses.json"
+
+func (clt *Client) timedPausesPath() string {
+	return path.Join(clt.CurrentConfigDirectory(), timedPausesFileName)
+}
+
+// Loads the timed pauses that were set before the app was last stopped, and schedules resuming the folders. Folders
+// whose pause ended while the app was not running are resumed right away.
+func (clt *Client) loadTimedPauses() {
+	data, err := os.ReadFile(clt.timedPausesPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("could not read timed pauses", "cause", err)
+		}
+		return
+	}
+
+	var pauses map[string]time.Time
+	if err := json.Unmarshal(data, &pauses); err != nil {
+		slog.Warn("could not parse timed pauses", "cause", err)
+		return
+	}
+
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	for folderID, resumeAt := range pauses {
+		clt.scheduleResumeLocked(folderID, resumeAt)
+	}
+}
+
+// Must be called with clt.mutex held
+func (clt *Client) saveTimedPausesLocked() error {
+	data, err := json.Marshal(clt.timedPauses)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(clt.timedPausesPath(), data, 0o600)
+}
+
+// Must be called with clt.mutex held
+func (clt *Client) scheduleResumeLocked(folderID string, resumeAt time.Time) {
+	if timer, ok := clt.timedPauseTimers[folderID]; ok {
+		timer.Stop()
+	}
+	clt.timedPauses[folderID] = resumeAt
+	clt.timedPauseTimers[folderID] = time.AfterFunc(max(0, time.Until(resumeAt)), func() {
+		clt.resumeAfterTimedPause(folderID, resumeAt)
+	})
+}
+
+func (clt *Client) resumeAfterTimedPause(folderID string, resumeAt time.Time) {
+	clt.mutex.Lock()
+	if clt.timedPauses[folderID] != resumeAt {
+		// The pause was extended or cancelled in the meantime
+		clt.mutex.Unlock()
+		return
+	}
+	clt.mutex.Unlock()
+
+	if fld := clt.FolderWithID(folderID); fld != nil && fld.IsPaused() {
+		slog.Info("resuming folder after timed pause", "folderID", folderID)
+		if err := fld.SetPaused(false); err != nil {
+			slog.Warn("could not resume folder after timed pause", "folderID", folderID, "cause", err)
+			return
+		}
+	}
+	clt.cancelTimedPause(folderID)
+}
+
+// Forgets about the timed pause for the folder, if any. The folder is not resumed.
+func (clt *Client) cancelTimedPause(folderID string) {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	if _, ok := clt.timedPauses[folderID]; !ok {
+		return
+	}
+	if timer, ok := clt.timedPauseTimers[folderID]; ok {
+		timer.Stop()
+		delete(clt.timedPauseTimers, folderID)
+	}
+	delete(clt.timedPauses, folderID)
+	if err := clt.saveTimedPausesLocked(); err != nil {
+		slog.Warn("could not save timed pauses", "cause", err)
+	}
+}
+
+// Pauses the folder and resumes it automatically after the specified number of seconds, also when the app is restarted
+// in the meantime. Resuming the folder manually cancels the timed pause.
+func (fld *Folder) PauseForSeconds(secs int) error {
+	if secs <= 0 {
+		return errors.New("pause duration must be positive")
+	}
+
+	if err := fld.SetPaused(true); err != nil {
+		return err
+	}
+
+	clt := fld.client
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+	clt.scheduleResumeLocked(fld.FolderID, time.Now().Add(time.Duration(secs)*time.Second))
+	return clt.saveTimedPausesLocked()
+}
+
+// Returns the time at which the folder will be resumed automatically, or nil when there is no timed pause
+func (fld *Folder) PausedUntil() *Date {
+	fld.client.mutex.Lock()
+	defer fld.client.mutex.Unlock()
+	if resumeAt, ok := fld.client.timedPauses[fld.FolderID]; ok {
+		return &Date{time: resumeAt}
+	}
+	return nil
+}
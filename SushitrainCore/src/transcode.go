@@ -0,0 +1,127 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// Swift-side interface that converts media (e.g. FLAC audio) to a format that can be played natively (e.g. AAC)
+type StreamTranscoder interface {
+	// Returns the MIME type that files of the source MIME type should be converted to, or an empty string when files of
+	// this type can be played as-is
+	TargetMIMEType(sourceMIMEType string) string
+
+	// Reads the source file from `source` and writes the converted file to `output` as it is produced
+	Transcode(source *TranscodeSource, sourceMIMEType string, targetMIMEType string, output *TranscodeOutput) error
+}
+
+// Provides random access to the original contents of a file that is being transcoded. Blocks are pulled from peers
+// when not available locally.
+type TranscodeSource struct {
+	content io.ReadSeeker
+	size    int64
+	mutex   sync.Mutex
+}
+
+func (src *TranscodeSource) Size() int64 {
+	return src.size
+}
+
+// Returns at most `length` bytes starting at `offset`. Fewer bytes are returned only at the end of the file.
+func (src *TranscodeSource) ReadAt(offset int64, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, errors.New("invalid range")
+	}
+	if offset >= src.size {
+		return []byte{}, nil
+	}
+	length = min(length, src.size-offset)
+
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
+	if _, err := src.content.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, length)
+	n, err := io.ReadFull(src.content, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// Receives transcoded data and sends it on to the requesting client
+type TranscodeOutput struct {
+	writer       io.Writer
+	context      context.Context
+	bytesWritten int64
+}
+
+// Returns an error when the data could not be delivered, e.g. because the client went away. Transcoding should then stop.
+func (out *TranscodeOutput) Write(data []byte) error {
+	if err := out.context.Err(); err != nil {
+		return err
+	}
+
+	n, err := out.writer.Write(data)
+	out.bytesWritten += int64(n)
+	if err != nil {
+		return err
+	}
+	if flusher, ok := out.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// Returns the MIME type the entry should be transcoded to when requested, or an empty string when the entry should be
+// served as-is (no transcoder is set, the entry cannot be rendered, or it can already be played natively)
+func (srv *StreamingServer) transcodeTargetFor(entry *Entry) string {
+	if srv.Transcoder == nil {
+		return ""
+	}
+
+	sourceMIMEType := entry.MIMEType()
+	if sourceMIMEType == "" {
+		return ""
+	}
+
+	targetMIMEType := srv.Transcoder.TargetMIMEType(sourceMIMEType)
+	if targetMIMEType == sourceMIMEType {
+		return ""
+	}
+	return targetMIMEType
+}
+
+// Streams the transcoded contents. As the size of the output is not known beforehand, range requests are not supported
+// and the response is not cacheable.
+func serveTranscoded(w http.ResponseWriter, r *http.Request, transcoder StreamTranscoder, content io.ReadSeeker, size int64, sourceMIMEType string, targetMIMEType string) {
+	setNoCacheHeaders(w)
+	w.Header().Set("Content-Type", targetMIMEType)
+	w.Header().Set("Accept-Ranges", "none")
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	source := &TranscodeSource{content: content, size: size}
+	output := &TranscodeOutput{writer: w, context: r.Context()}
+	if err := transcoder.Transcode(source, sourceMIMEType, targetMIMEType, output); err != nil {
+		slog.Warn("transcoding failed", "cause", err, "source", sourceMIMEType, "target", targetMIMEType, "bytesWritten", output.bytesWritten)
+		if output.bytesWritten == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
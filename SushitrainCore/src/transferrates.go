@@ -0,0 +1,148 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Samples shorter than this are not used to update the rate, to prevent wild swings when called often
+const minTransferRateSampleInterval = 500 * time.Millisecond
+
+// Weight of a new sample in the (exponentially) smoothed rate
+const transferRateSmoothing = 0.5
+
+type TransferRates struct {
+	DownBytesPerSecond int64
+	UpBytesPerSecond   int64
+}
+
+// Calculates a smoothed transfer rate from subsequent samples of the number of bytes transferred per item
+type transferRateSampler struct {
+	lastSample map[string]int64
+	lastTime   time.Time
+	rate       float64
+}
+
+func (s *transferRateSampler) sample(now time.Time, current map[string]int64) int64 {
+	if !s.lastTime.IsZero() {
+		elapsed := now.Sub(s.lastTime)
+		if elapsed < minTransferRateSampleInterval {
+			return int64(s.rate)
+		}
+
+		// Items that finished have disappeared from the current sample; items that are new are counted from zero
+		var delta int64 = 0
+		for key, bytesDone := range current {
+			delta += max(0, bytesDone-s.lastSample[key])
+		}
+
+		instantRate := float64(delta) / elapsed.Seconds()
+		s.rate = transferRateSmoothing*instantRate + (1.0-transferRateSmoothing)*s.rate
+	}
+
+	s.lastSample = current
+	s.lastTime = now
+	return int64(s.rate)
+}
+
+// Returns the bytes done per file being downloaded, keyed by folder ID and path. Should be called with the mutex held.
+func (clt *Client) downloadProgressSample(folderID string) map[string]int64 {
+	sample := make(map[string]int64)
+	for fid, files := range clt.downloadProgress {
+		if folderID != "" && fid != folderID {
+			continue
+		}
+		for path, progress := range files {
+			sample[fid+"\x00"+path] = progress.BytesDone
+		}
+	}
+	return sample
+}
+
+// Returns the (estimated) bytes sent per file being uploaded, keyed by device ID, folder ID and path. Should be called
+// with the mutex held.
+func (clt *Client) uploadProgressSample() map[string]int64 {
+	sample := make(map[string]int64)
+	if clt.app == nil || clt.app.Internals == nil {
+		return sample
+	}
+
+	for deviceID, folders := range clt.uploadProgress {
+		for folderID, files := range folders {
+			for path, blocksTransferred := range files {
+				info, ok, err := clt.app.Internals.GlobalFileInfo(folderID, path)
+				if !ok || err != nil {
+					continue
+				}
+				sample[deviceID+"\x00"+folderID+"\x00"+path] = min(info.Size, int64(blocksTransferred)*int64(info.BlockSize()))
+			}
+		}
+	}
+	return sample
+}
+
+// Returns the current (smoothed) download and upload rates. The rates are updated each time this is called, so call
+// this periodically (e.g. every second) for accurate results.
+func (clt *Client) CurrentTransferRates() *TransferRates {
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	now := time.Now()
+	return &TransferRates{
+		DownBytesPerSecond: clt.downloadRateSampler.sample(now, clt.downloadProgressSample("")),
+		UpBytesPerSecond:   clt.uploadRateSampler.sample(now, clt.uploadProgressSample()),
+	}
+}
+
+// Returns the current (smoothed) download rate for this folder in bytes per second. Like Client.CurrentTransferRates,
+// this should be called periodically.
+func (fld *Folder) CurrentDownloadRate() int64 {
+	clt := fld.client
+	clt.mutex.Lock()
+	defer clt.mutex.Unlock()
+
+	if clt.folderDownloadRateSamplers == nil {
+		clt.folderDownloadRateSamplers = make(map[string]*transferRateSampler)
+	}
+	sampler, ok := clt.folderDownloadRateSamplers[fld.FolderID]
+	if !ok {
+		sampler = &transferRateSampler{}
+		clt.folderDownloadRateSamplers[fld.FolderID] = sampler
+	}
+	return sampler.sample(time.Now(), clt.downloadProgressSample(fld.FolderID))
+}
+
+// Returns the moment at which the remaining bytes will have been transferred at the given rate, or nil when nothing
+// remains or no estimate can be made
+func estimateCompletion(now time.Time, remainingBytes int64, bytesPerSecond int64) *time.Time {
+	if remainingBytes <= 0 || bytesPerSecond <= 0 {
+		return nil
+	}
+	eta := now.Add(time.Duration(float64(remainingBytes) / float64(bytesPerSecond) * float64(time.Second)))
+	return &eta
+}
+
+// Returns the projected time at which the folder will have pulled everything it needs, based on the remaining need bytes
+// and the current (smoothed) download rate of the folder. Returns nil when the folder needs nothing or is not currently
+// downloading. The rate is sampled each time this is called (see CurrentDownloadRate), so call this periodically.
+func (fld *Folder) EstimatedSyncCompletion() (*Date, error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return nil, ErrStillLoading
+	}
+
+	needSize, err := fld.client.app.Internals.NeedSize(fld.FolderID, protocol.LocalDeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if eta := estimateCompletion(time.Now(), needSize.Bytes, fld.CurrentDownloadRate()); eta != nil {
+		return &Date{time: *eta}, nil
+	}
+	return nil, nil
+}
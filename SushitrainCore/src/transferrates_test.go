@@ -0,0 +1,22 @@
+package sushitrain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateCompletion(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	if eta := estimateCompletion(now, 0, 1000); eta != nil {
+		t.Error("nothing remaining should not give an estimate")
+	}
+	if eta := estimateCompletion(now, 1000, 0); eta != nil {
+		t.Error("zero rate should not give an estimate")
+	}
+
+	eta := estimateCompletion(now, 720_000, 1000)
+	if eta == nil || !eta.Equal(now.Add(12*time.Minute)) {
+		t.Errorf("unexpected estimate: %v", eta)
+	}
+}
@@ -39,6 +39,18 @@ func NewListOfStrings() *ListOfStrings {
 	return List([]string{})
 }
 
+type ListOfEntries struct {
+	data []*Entry
+}
+
+func (lst *ListOfEntries) Count() int {
+	return len(lst.data)
+}
+
+func (lst *ListOfEntries) ItemAt(index int) *Entry {
+	return lst.data[index]
+}
+
 func Map[T, U any](ts []T, f func(T) U) []U {
 	us := make([]U, len(ts))
 	for i := range ts {
@@ -107,6 +119,7 @@ var mimesByExtension = map[string]string{
 	".js":     "text/javascript",
 	".json":   "application/json",
 	".jsonld": "application/ld+json",
+	".md":     "text/markdown",
 	".mid":    "audio/midi",
 	".midi":   "audio/midi",
 	".mjs":    "text/javascript",
@@ -205,6 +218,78 @@ func (fcts *FolderCounts) add(other *FolderCounts) {
 	fcts.Directories += other.Directories
 }
 
+const (
+	FileTypeCategoryImage    = "image"
+	FileTypeCategoryVideo    = "video"
+	FileTypeCategoryAudio    = "audio"
+	FileTypeCategoryDocument = "document"
+	FileTypeCategoryOther    = "other"
+)
+
+type FileTypeBreakdown struct {
+	Image    *FolderCounts
+	Video    *FolderCounts
+	Audio    *FolderCounts
+	Document *FolderCounts
+	Other    *FolderCounts
+}
+
+func newFileTypeBreakdown() *FileTypeBreakdown {
+	return &FileTypeBreakdown{
+		Image:    &FolderCounts{},
+		Video:    &FolderCounts{},
+		Audio:    &FolderCounts{},
+		Document: &FolderCounts{},
+		Other:    &FolderCounts{},
+	}
+}
+
+func (ftb *FileTypeBreakdown) countsFor(category string) *FolderCounts {
+	switch category {
+	case FileTypeCategoryImage:
+		return ftb.Image
+	case FileTypeCategoryVideo:
+		return ftb.Video
+	case FileTypeCategoryAudio:
+		return ftb.Audio
+	case FileTypeCategoryDocument:
+		return ftb.Document
+	default:
+		return ftb.Other
+	}
+}
+
+var documentMIMETypePrefixes = []string{
+	"text/",
+	"application/pdf",
+	"application/msword",
+	"application/rtf",
+	"application/epub+zip",
+	"application/vnd.ms-",
+	"application/vnd.oasis.opendocument.",
+	"application/vnd.openxmlformats-officedocument.",
+}
+
+// ext should include the dot
+func FileTypeCategoryForExtension(ext string) string {
+	mime := MIMETypeForExtension(ext)
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		return FileTypeCategoryImage
+	case strings.HasPrefix(mime, "video/"):
+		return FileTypeCategoryVideo
+	case strings.HasPrefix(mime, "audio/"):
+		return FileTypeCategoryAudio
+	}
+
+	for _, prefix := range documentMIMETypePrefixes {
+		if strings.HasPrefix(mime, prefix) {
+			return FileTypeCategoryDocument
+		}
+	}
+	return FileTypeCategoryOther
+}
+
 func TriggerGC() {
 	runtime.GC()
 }
@@ -0,0 +1,218 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+type VerifyDelegate interface {
+	// Called for each local file whose contents do not match the block hashes in the global index
+	OnMismatch(path string)
+	OnError(error string)
+	OnProgress(fraction float64)
+	// Called when verification (and repair, if requested) has completed, with the number of files that were checked
+	// and the number of files that did not match
+	OnFinished(checked int, mismatched int)
+	IsCancelled() bool
+}
+
+// Adapts a VerifyDelegate so it can be used for downloads while repairing
+type verifyDownloadDelegate struct {
+	parent VerifyDelegate
+}
+
+func (v *verifyDownloadDelegate) IsCancelled() bool {
+	return v.parent.IsCancelled()
+}
+
+func (v *verifyDownloadDelegate) OnError(err string) {
+	v.parent.OnError(err)
+}
+
+func (v *verifyDownloadDelegate) OnFinished(path string) {
+	// Swallow
+}
+
+func (v *verifyDownloadDelegate) OnProgress(fraction float64) {
+	// Swallow
+}
+
+func (v *verifyDownloadDelegate) OnStalled(stalled bool) {
+	// Swallow
+}
+
+var _ DownloadDelegate = &verifyDownloadDelegate{}
+
+type verifyCandidate struct {
+	name string
+	size int64
+}
+
+// Returns whether the local file has the same size and modification time as the global file. Files that differ are
+// either still to be pulled or locally changed; a normal scan will deal with those.
+func isVerifyCandidate(ffs fs.Filesystem, name string, size int64, modTime time.Time, modTimeWindow time.Duration) bool {
+	stat, err := ffs.Lstat(osutil.NativeFilename(name))
+	if err != nil || !stat.IsRegular() || stat.Size() != size {
+		return false
+	}
+	return stat.ModTime().Sub(modTime).Abs() <= modTimeWindow
+}
+
+// Returns true when the contents of the local file match the block hashes of the global file
+func verifyLocalFile(ffs fs.Filesystem, info protocol.FileInfo) (bool, error) {
+	fd, err := ffs.Open(osutil.NativeFilename(info.Name))
+	if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+
+	stat, err := fd.Stat()
+	if err != nil {
+		return false, err
+	}
+	return verifyDownloadedFile(fd, stat.Size(), info) == nil, nil
+}
+
+// Receives the results of verifyLocalFiles
+type verifyReporter interface {
+	OnMismatch(path string)
+	OnError(error string)
+	OnProgress(fraction float64)
+	IsCancelled() bool
+}
+
+// Hashes all locally present selected files whose size and modification time match the global version, and compares
+// them against the block hashes in the global index. Returns the number of files checked and the entries of the files
+// that did not match. Returns early (with cancelled set to true) when the reporter is cancelled.
+func (fld *Folder) verifyLocalFiles(reporter verifyReporter) (checked int, mismatches []*Entry, cancelled bool, err error) {
+	if fld.client.app == nil || fld.client.app.Internals == nil {
+		return 0, nil, false, ErrStillLoading
+	}
+
+	fc := fld.folderConfiguration()
+	if fc == nil {
+		return 0, nil, false, errors.New("folder does not exist")
+	}
+
+	ffs, err := fld.filesystem()
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	matcher, err := fld.loadIgnores()
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	candidates := make([]verifyCandidate, 0)
+	var totalBytes int64 = 0
+	for f, err := range zipError(fld.client.app.Internals.AllGlobalFiles(fld.FolderID)) {
+		if err != nil {
+			return 0, nil, false, err
+		}
+		if reporter.IsCancelled() {
+			return 0, nil, true, nil
+		}
+
+		if f.Deleted || f.Type != protocol.FileInfoTypeFile || matcher.Match(f.Name).IsIgnored() {
+			continue
+		}
+		if isVerifyCandidate(ffs, f.Name, f.Size, f.ModTime(), fc.ModTimeWindow()) {
+			candidates = append(candidates, verifyCandidate{name: f.Name, size: f.Size})
+			totalBytes += f.Size
+		}
+	}
+
+	slog.Info("verify local files", "folderID", fld.FolderID, "files", len(candidates), "bytes", totalBytes)
+	reporter.OnProgress(0.0)
+
+	var doneBytes int64 = 0
+	mismatches = make([]*Entry, 0)
+	for _, candidate := range candidates {
+		if reporter.IsCancelled() {
+			return checked, mismatches, true, nil
+		}
+
+		entry, err := fld.GetFileInformation(candidate.name)
+		if err != nil || entry == nil {
+			reporter.OnError(fmt.Sprintf("%s: file not found", candidate.name))
+		} else if ok, err := verifyLocalFile(ffs, entry.info); err != nil {
+			reporter.OnError(fmt.Sprintf("%s: %s", candidate.name, err.Error()))
+		} else {
+			checked += 1
+			if !ok {
+				slog.Warn("local file does not match global index", "folderID", fld.FolderID, "path", candidate.name)
+				mismatches = append(mismatches, entry)
+				reporter.OnMismatch(candidate.name)
+			}
+		}
+
+		doneBytes += candidate.size
+		if totalBytes > 0 {
+			reporter.OnProgress(float64(doneBytes) / float64(totalBytes))
+		}
+	}
+	return checked, mismatches, false, nil
+}
+
+// Recomputes the block hashes of all locally present selected files and compares them against the global index. This is
+// a lot heavier than a scan (which only looks at size and modification time) and should only be started on explicit
+// request of the user. Only files whose size and modification time match the global version are checked.
+//
+// When repair is true, each mismatching file is downloaded again from peers, verified against the block hashes and put in
+// place with the global metadata. Merely rescanning the file would not help: the scan trusts size and modification time,
+// so it would not notice the corruption, and touching the file would make Syncthing send the corrupt contents to other
+// devices instead.
+func (fld *Folder) VerifyLocalFiles(delegate VerifyDelegate, repair bool) {
+	go func() {
+		checked, mismatches, cancelled, err := fld.verifyLocalFiles(delegate)
+		if err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+		if cancelled {
+			return
+		}
+
+		if repair && len(mismatches) > 0 {
+			fc := fld.folderConfiguration()
+			if fc == nil {
+				delegate.OnError("folder does not exist")
+				return
+			}
+
+			for _, entry := range mismatches {
+				if delegate.IsCancelled() {
+					return
+				}
+
+				subDelegate := &subDownloadDelegate{
+					parent: &verifyDownloadDelegate{parent: delegate},
+					errorCallback: func(err string) {
+						delegate.OnError(fmt.Sprintf("%s: %s", entry.Path(), err))
+					},
+					progressCallback: func(fraction float64) {},
+				}
+				if err := fld.downloadIntoFolder(fc, entry, subDelegate, true); err != nil {
+					subDelegate.OnError(err.Error())
+				}
+			}
+		}
+
+		if delegate.IsCancelled() {
+			return
+		}
+		delegate.OnFinished(checked, len(mismatches))
+	}()
+}
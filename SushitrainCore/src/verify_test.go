@@ -0,0 +1,63 @@
+package sushitrain
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestVerifyLocalFile(t *testing.T) {
+	dir := t.TempDir()
+	ffs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
+
+	data := []byte("hello world, this is a test file")
+	hash := sha256.Sum256(data)
+	info := protocol.FileInfo{
+		Name:   "sub/file.txt",
+		Size:   int64(len(data)),
+		Blocks: []protocol.BlockInfo{{Offset: 0, Size: len(data), Hash: hash[:]}},
+	}
+
+	localPath := filepath.Join(dir, "sub", "file.txt")
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Unix(1700000000, 0)
+	if err := os.Chtimes(localPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isVerifyCandidate(ffs, info.Name, info.Size, modTime, 0) {
+		t.Error("file with matching size and mtime should be a candidate")
+	}
+	if isVerifyCandidate(ffs, info.Name, info.Size, modTime.Add(time.Hour), time.Second) {
+		t.Error("file with different mtime should not be a candidate")
+	}
+	if isVerifyCandidate(ffs, info.Name, info.Size+1, modTime, 0) {
+		t.Error("file with different size should not be a candidate")
+	}
+	if isVerifyCandidate(ffs, "missing.txt", info.Size, modTime, 0) {
+		t.Error("missing file should not be a candidate")
+	}
+
+	if ok, err := verifyLocalFile(ffs, info); err != nil || !ok {
+		t.Errorf("intact file should verify: ok=%v err=%v", ok, err)
+	}
+
+	corrupt := append([]byte{}, data...)
+	corrupt[3] ^= 0xff
+	if err := os.WriteFile(localPath, corrupt, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := verifyLocalFile(ffs, info); err != nil || ok {
+		t.Errorf("corrupt file should not verify: ok=%v err=%v", ok, err)
+	}
+}
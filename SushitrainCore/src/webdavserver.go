@@ -0,0 +1,357 @@
+// Copyright (C) 2025 Tommy van der Vorst
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+package sushitrain
+
+import (
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"golang.org/x/exp/slog"
+)
+
+// A read-only WebDAV server for a folder (or subdirectory thereof). Files are served from the global index, blocks that
+// are not available locally are fetched on demand from peers. Authentication works the same as for FolderServer.
+type WebDAVServer struct {
+	listener     net.Listener
+	client       *Client
+	folderID     string
+	subdirectory string
+	certificate  *selfSignedCertificate
+	cookieToken  string
+
+	// Requests for paths of which any component matches one of these patterns are answered with 404, and matching
+	// entries are left out of PROPFIND listings
+	denied pathDenyList
+}
+
+const webdavAllowedMethods = "OPTIONS, GET, HEAD, PROPFIND"
+
+type webdavMultistatus struct {
+	XMLName   xml.Name         `xml:"D:multistatus"`
+	Namespace string           `xml:"xmlns:D,attr"`
+	Responses []webdavResponse `xml:"D:response"`
+}
+
+type webdavResponse struct {
+	Href     string         `xml:"D:href"`
+	Propstat webdavPropstat `xml:"D:propstat"`
+}
+
+type webdavPropstat struct {
+	Prop   webdavProp `xml:"D:prop"`
+	Status string     `xml:"D:status"`
+}
+
+type webdavProp struct {
+	DisplayName   string             `xml:"D:displayname"`
+	ResourceType  webdavResourceType `xml:"D:resourcetype"`
+	ContentLength *int64             `xml:"D:getcontentlength,omitempty"`
+	ContentType   string             `xml:"D:getcontenttype,omitempty"`
+	LastModified  string             `xml:"D:getlastmodified,omitempty"`
+}
+
+type webdavResourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+func NewWebDAVServer(client *Client, folderID string, subdirectory string) *WebDAVServer {
+	cert, err := newSelfSignedCertificate()
+	if err != nil {
+		slog.Error("could not create self signed certificate", "cause", err)
+		return nil
+	}
+
+	return &WebDAVServer{
+		folderID:     folderID,
+		subdirectory: subdirectory,
+		listener:     nil,
+		client:       client,
+		certificate:  cert,
+		cookieToken:  newCookieToken(),
+		denied:       newPathDenyList(defaultFolderServerDeniedPatterns),
+	}
+}
+
+// Set the patterns (in filepath.Match syntax) that are matched against each path component of a request. Requests
+// for paths with a matching component are denied. Pass nil or an empty list to allow all paths.
+func (srv *WebDAVServer) SetDeniedPatterns(patterns *ListOfStrings) error {
+	return srv.denied.set(patterns)
+}
+
+func (srv *WebDAVServer) CookieValue() string {
+	return srv.cookieToken
+}
+
+func (srv *WebDAVServer) CookieName() string {
+	return "__sushitrain_webdav_server_cookie"
+}
+
+func (srv *WebDAVServer) CertificateFingerprintSHA256() []byte {
+	fingerprint := srv.certificate.fingerprintSha256()
+	return fingerprint[:]
+}
+
+func (srv *WebDAVServer) Shutdown() {
+	if srv.listener != nil {
+		srv.listener.Close()
+		srv.listener = nil
+	}
+}
+
+func (srv *WebDAVServer) Listen() error {
+	// Close existing listener
+	srv.Shutdown()
+
+	config, err := srv.certificate.tlsConfig()
+	if err != nil {
+		slog.Error("could not obtain certificate", "cause", err)
+		return err
+	}
+
+	listener, err := tls.Listen("tcp", ":0", config)
+	if err != nil {
+		slog.Error("could not listen", "cause", err)
+		return err
+	}
+
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.handle(w, r)
+	}))
+
+	srv.listener = listener
+	slog.Info("WebDAV service listening", "port", srv.port())
+	return nil
+}
+
+func (srv *WebDAVServer) port() int {
+	return srv.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (srv *WebDAVServer) URL() string {
+	url := url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("localhost:%d", srv.port()),
+		Path:   "/",
+	}
+	return url.String()
+}
+
+func (srv *WebDAVServer) handle(w http.ResponseWriter, r *http.Request) {
+	slog.Info("webdav server serve", "folderID", srv.folderID, "subdirectory", srv.subdirectory, "method", r.Method, "path", r.URL.Path)
+
+	// Check whether the client sent the authentication cookie
+	cookie, err := r.Cookie(srv.CookieName())
+	if err != nil {
+		http.Error(w, "cookie not found", http.StatusBadRequest)
+		return
+	}
+
+	if cookie.Value != srv.CookieValue() {
+		http.Error(w, "invalid cookie", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		w.Header().Set("DAV", "1")
+		w.Header().Set("Allow", webdavAllowedMethods)
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET", "HEAD", "PROPFIND":
+		break
+	default:
+		w.Header().Set("Allow", webdavAllowedMethods)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Remove slash prefixes and suffixes
+	requestPath := strings.Trim(r.URL.Path, "/")
+	if requestPath != "" && !filepath.IsLocal(requestPath) {
+		slog.Warn("webdav server path is not local", "path", r.URL.Path)
+		http.Error(w, "requested path is not local", http.StatusBadRequest)
+		return
+	}
+
+	if srv.denied.isDenied(requestPath) {
+		slog.Info("webdav server path is denied", "path", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	stFolder := srv.client.FolderWithID(srv.folderID)
+	if stFolder == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	pathInFolder := path.Join(srv.subdirectory, requestPath)
+	if pathInFolder == "." {
+		pathInFolder = ""
+	}
+
+	// The root of the served directory is always a collection
+	var stEntry *Entry = nil
+	if pathInFolder != "" {
+		stEntry, err = stFolder.GetFileInformation(pathInFolder)
+		if err != nil {
+			slog.Warn("webdav server entry not found", "path", r.URL.Path, "pathInFolder", pathInFolder)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if stEntry == nil || stEntry.IsDeleted() || stEntry.IsSymlink() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+	isCollection := stEntry == nil || stEntry.IsDirectory()
+
+	if r.Method == "PROPFIND" {
+		srv.propfind(w, r, stFolder, requestPath, pathInFolder, stEntry)
+		return
+	}
+
+	if isCollection {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND")
+		http.Error(w, "cannot GET a collection", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Set MIME type
+	mime := stEntry.MIMEType()
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+	w.Header().Add("Content-type", mime)
+
+	// Obtain global file info
+	m := srv.client.app.Internals
+	info, ok, err := m.GlobalFileInfo(srv.folderID, pathInFolder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	// Actually send the file
+	serveEntry(w, r, srv.folderID, stEntry, info, srv.client.app.Internals, srv.client.Measurements, nil, nil)
+}
+
+func (srv *WebDAVServer) propfind(w http.ResponseWriter, r *http.Request, stFolder *Folder, requestPath string, pathInFolder string, stEntry *Entry) {
+	// Infinite depth is not supported; treat it as depth 1 (which is what most clients ask for anyway)
+	depth := r.Header.Get("Depth")
+
+	responses := make([]webdavResponse, 0)
+	if stEntry == nil {
+		responses = append(responses, webdavCollectionResponse(requestPath, stFolder.Label(), time.Time{}))
+	} else if stEntry.IsDirectory() {
+		responses = append(responses, webdavCollectionResponse(requestPath, stEntry.FileName(), stEntry.info.ModTime()))
+	} else {
+		responses = append(responses, webdavFileResponse(requestPath, stEntry.FileName(), stEntry.Size(), stEntry.info.ModTime()))
+	}
+
+	if depth != "0" && (stEntry == nil || stEntry.IsDirectory()) {
+		children, err := stFolder.listEntries(pathInFolder, false, false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, child := range children {
+			if srv.denied.isDenied(child.Name) {
+				continue
+			}
+
+			childPath := path.Join(requestPath, child.Name)
+			switch child.Type {
+			case protocol.FileInfoTypeDirectory.String():
+				responses = append(responses, webdavCollectionResponse(childPath, child.Name, child.ModTime))
+			case protocol.FileInfoTypeFile.String():
+				responses = append(responses, webdavFileResponse(childPath, child.Name, child.Size, child.ModTime))
+			default:
+				// Symlinks are not served
+				continue
+			}
+		}
+	}
+
+	body, err := xml.Marshal(webdavMultistatus{
+		Namespace: "DAV:",
+		Responses: responses,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+func webdavHref(requestPath string, isCollection bool) string {
+	href := "/" + requestPath
+	if isCollection && !strings.HasSuffix(href, "/") {
+		href += "/"
+	}
+	return (&url.URL{Path: href}).EscapedPath()
+}
+
+func webdavLastModified(modTime time.Time) string {
+	if modTime.IsZero() {
+		return ""
+	}
+	return modTime.UTC().Format(http.TimeFormat)
+}
+
+func webdavCollectionResponse(requestPath string, name string, modTime time.Time) webdavResponse {
+	return webdavResponse{
+		Href: webdavHref(requestPath, true),
+		Propstat: webdavPropstat{
+			Prop: webdavProp{
+				DisplayName:  name,
+				ResourceType: webdavResourceType{Collection: &struct{}{}},
+				LastModified: webdavLastModified(modTime),
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+func webdavFileResponse(requestPath string, name string, size int64, modTime time.Time) webdavResponse {
+	mime := MIMETypeForExtension(filepath.Ext(name))
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+
+	return webdavResponse{
+		Href: webdavHref(requestPath, false),
+		Propstat: webdavPropstat{
+			Prop: webdavProp{
+				DisplayName:   name,
+				ResourceType:  webdavResourceType{},
+				ContentLength: &size,
+				ContentType:   mime,
+				LastModified:  webdavLastModified(modTime),
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
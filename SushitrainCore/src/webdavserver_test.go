@@ -0,0 +1,74 @@
+package sushitrain
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebDAVHref(t *testing.T) {
+	cases := [][]string{
+		{"", "/"},
+		{"a b", "/a%20b/"},
+		{"dir/sub", "/dir/sub/"},
+	}
+
+	for _, c := range cases {
+		if href := webdavHref(c[0], true); href != c[1] {
+			t.Errorf("unexpected href for %q: %q (expected %q)", c[0], href, c[1])
+		}
+	}
+
+	if href := webdavHref("dir/file.txt", false); href != "/dir/file.txt" {
+		t.Errorf("unexpected file href: %q", href)
+	}
+}
+
+func TestWebDAVMultistatus(t *testing.T) {
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	body, err := xml.Marshal(webdavMultistatus{
+		Namespace: "DAV:",
+		Responses: []webdavResponse{
+			webdavCollectionResponse("", "root", time.Time{}),
+			webdavFileResponse("photo.jpg", "photo.jpg", 1234, modTime),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	xmlString := string(body)
+	expected := []string{
+		`<D:multistatus xmlns:D="DAV:">`,
+		`<D:href>/</D:href>`,
+		`<D:resourcetype><D:collection></D:collection></D:resourcetype>`,
+		`<D:href>/photo.jpg</D:href>`,
+		`<D:getcontentlength>1234</D:getcontentlength>`,
+		`<D:getcontenttype>image/jpeg</D:getcontenttype>`,
+		`<D:getlastmodified>Thu, 02 Jan 2025 03:04:05 GMT</D:getlastmodified>`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(xmlString, e) {
+			t.Errorf("expected %q in PROPFIND response: %s", e, xmlString)
+		}
+	}
+}
+
+func TestWebDAVDeniedPaths(t *testing.T) {
+	srv := &WebDAVServer{cookieToken: "token", denied: newPathDenyList(defaultFolderServerDeniedPatterns)}
+
+	for _, requestPath := range []string{"/.stfolder", "/sub/.git/config", "/Thumbs.db"} {
+		for _, method := range []string{"GET", "PROPFIND"} {
+			r := httptest.NewRequest(method, requestPath, nil)
+			r.AddCookie(&http.Cookie{Name: srv.CookieName(), Value: srv.CookieValue()})
+			w := httptest.NewRecorder()
+			srv.handle(w, r)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s %s should be denied, got status %d", method, requestPath, w.Code)
+			}
+		}
+	}
+}
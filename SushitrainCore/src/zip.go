@@ -34,8 +34,12 @@ type Archive interface {
 	IsDirectory(path string) bool
 	Name() string
 	File(path string) (ArchiveFile, error)
+	ExtractAll(toDir string, delegate DownloadDelegate)
 }
 
+// Size of the buffer used to copy each file when extracting a complete archive
+const archiveExtractBufferSize = 64 * 1024
+
 type entryArchiveFile struct {
 	archive *entryArchive
 	file    *zip.File
@@ -160,6 +164,99 @@ func (ea *entryArchive) allFiles() ([]*zip.File, error) {
 	return ea.files, nil
 }
 
+// Extracts all files in the archive to the specified directory, creating subdirectories as needed. Entries whose path
+// would end up outside of toDir are skipped.
+func (ea *entryArchive) ExtractAll(toDir string, delegate DownloadDelegate) {
+	go ea.extractAll(toDir, delegate)
+}
+
+func (ea *entryArchive) extractAll(toDir string, delegate DownloadDelegate) {
+	files, err := ea.allFiles()
+	if err != nil {
+		delegate.OnError(err.Error())
+		return
+	}
+
+	var totalBytes uint64 = 0
+	for _, file := range files {
+		totalBytes += file.UncompressedSize64
+	}
+
+	if err := os.MkdirAll(toDir, 0o700); err != nil {
+		delegate.OnError(err.Error())
+		return
+	}
+
+	delegate.OnProgress(0.0)
+	buffer := make([]byte, archiveExtractBufferSize)
+	var doneBytes uint64 = 0
+
+	for _, file := range files {
+		targetPath, ok := archiveExtractionPath(toDir, file.Name)
+		if !ok {
+			slog.Warn("skipping zip entry outside of target directory", "name", file.Name)
+			doneBytes += file.UncompressedSize64
+			continue
+		}
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(targetPath, 0o700); err != nil {
+				delegate.OnError(err.Error())
+				return
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0o700); err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+
+		cReader := cancelableReader{
+			delegate:   delegate,
+			totalBytes: max(totalBytes, 1),
+			readBytes:  doneBytes,
+		}
+		if err := extractArchiveFile(file, targetPath, &cReader, buffer); err != nil {
+			delegate.OnError(err.Error())
+			return
+		}
+		doneBytes += file.UncompressedSize64
+	}
+
+	delegate.OnProgress(1.0)
+	delegate.OnFinished(toDir)
+}
+
+func extractArchiveFile(file *zip.File, targetPath string, cReader *cancelableReader, buffer []byte) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	outFile, err := os.Create(targetPath)
+	if err != nil {
+		return err
+	}
+
+	cReader.reader = reader
+	_, err = io.CopyBuffer(outFile, cReader, buffer)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Returns the path to which an archive entry should be extracted, or false if the entry would end up outside of toDir
+func archiveExtractionPath(toDir string, name string) (string, bool) {
+	name = filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if name == "" || !filepath.IsLocal(name) {
+		return "", false
+	}
+	return filepath.Join(toDir, name), true
+}
+
 // ReadAt implements io.ReaderAt.
 func (ea *entryArchive) ReadAt(p []byte, off int64) (n int, err error) {
 	if buffer, err := ea.entry.FetchLocal(off, int64(len(p))); err == nil {
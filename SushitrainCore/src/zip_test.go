@@ -30,6 +30,9 @@ func (t *testDownloadDelegate) OnProgress(fraction float64) {
 	t.progress = append(t.progress, fraction)
 }
 
+func (t *testDownloadDelegate) OnStalled(stalled bool) {
+}
+
 func TestArchiveDirectoryDownloadHandlesImplicitSubdirectories(t *testing.T) {
 	var buffer bytes.Buffer
 	writer := zip.NewWriter(&buffer)
@@ -93,3 +96,69 @@ func TestArchiveDirectoryDownloadHandlesImplicitSubdirectories(t *testing.T) {
 		t.Fatalf("unexpected file contents: %q", string(downloadedBytes))
 	}
 }
+
+func TestArchiveExtractAllRefusesEscapingEntries(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	entries := map[string]string{
+		"../evil.txt":          "evil",
+		"sub/../../evil2.txt":  "evil",
+		"empty/":               "",
+		"docs/readme.txt":      "hello",
+		"docs/nested/deep.txt": "world",
+	}
+	for name, contents := range entries {
+		fileWriter, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+		if _, err := fileWriter.Write([]byte(contents)); err != nil {
+			t.Fatalf("Write %s: %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close zip writer: %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	baseDir := t.TempDir()
+	toDir := filepath.Join(baseDir, "out")
+	archive := &entryArchive{files: reader.File}
+	delegate := &testDownloadDelegate{}
+	archive.extractAll(toDir, delegate)
+
+	if delegate.err != "" {
+		t.Fatalf("extractAll returned error: %s", delegate.err)
+	}
+	if delegate.finished != toDir {
+		t.Fatalf("extractAll did not finish")
+	}
+	if last := delegate.progress[len(delegate.progress)-1]; last != 1.0 {
+		t.Errorf("unexpected final progress: %f", last)
+	}
+
+	for _, escaped := range []string{filepath.Join(baseDir, "evil.txt"), filepath.Join(baseDir, "evil2.txt")} {
+		if _, err := os.Stat(escaped); !os.IsNotExist(err) {
+			t.Errorf("entry escaped target directory: %s", escaped)
+		}
+	}
+
+	for name, contents := range map[string]string{"docs/readme.txt": "hello", "docs/nested/deep.txt": "world"} {
+		extracted, err := os.ReadFile(filepath.Join(toDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("ReadFile %s: %v", name, err)
+		}
+		if string(extracted) != contents {
+			t.Errorf("unexpected contents for %s: %q", name, string(extracted))
+		}
+	}
+
+	if info, err := os.Stat(filepath.Join(toDir, "empty")); err != nil || !info.IsDir() {
+		t.Errorf("directory entry was not created: %v", err)
+	}
+}